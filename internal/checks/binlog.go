@@ -0,0 +1,232 @@
+package checks
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// RunBinlogChecks reports the binary log configuration, its retention and
+// the space the logs take. Only the first check is reported when the binary
+// log is disabled.
+func RunBinlogChecks(m *db.MySQL, h *host.Info) []Check {
+	if !strings.EqualFold(m.Vars["log_bin"], "ON") && !m.DryRun() {
+		return runAll(func() Check { return checkBinlogEnabled(m) })
+	}
+	return runAll(
+		func() Check { return checkBinlogEnabled(m) },
+		func() Check { return checkBinlogFormat(m) },
+		func() Check { return checkSyncBinlog(m) },
+		func() Check { return checkBinlogRetention(m) },
+		func() Check { return checkBinlogSize(m, h) },
+		func() Check { return checkBinlogCacheDiskUse(m, "") },
+		func() Check { return checkBinlogCacheDiskUse(m, "stmt_") },
+	)
+}
+
+func checkBinlogEnabled(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Binary Log",
+		Threshold:   "enabled OK, disabled WARN",
+		Description: "Whether the server writes a binary log (log_bin).",
+		Detail: "The binary log records every change and is what replicas read and what " +
+			"point-in-time recovery replays on top of a backup. Without it a restore " +
+			"can only return to the moment the backup was taken, and no replica can be " +
+			"attached without a restart.",
+	}
+
+	if !strings.EqualFold(m.Vars["log_bin"], "ON") {
+		c.Value = "disabled"
+		c.Level = LevelWarn
+		return c
+	}
+	c.Value = "enabled"
+	if base := m.Vars["log_bin_basename"]; base != "" {
+		c.Value += " (" + base + ")"
+	}
+	c.Level = LevelOK
+	return c
+}
+
+func checkBinlogFormat(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Binlog Format",
+		Threshold:   "ROW OK, MIXED/STATEMENT WARN",
+		Description: "binlog_format and binlog_row_image.",
+		Detail: "ROW logs the changed rows themselves, so replicas and point-in-time " +
+			"recovery reproduce exactly what happened on the source. STATEMENT logs " +
+			"the SQL text, which replays differently for non-deterministic statements " +
+			"(LIMIT without ORDER BY, UUID(), triggers); MIXED falls back to ROW only " +
+			"for statements the server recognises as unsafe. binlog_row_image=MINIMAL " +
+			"logs only changed columns, which saves space but leaves less to repair a " +
+			"diverged replica or audit a change with.",
+	}
+
+	format := m.Vars["binlog_format"]
+	if format == "" {
+		return skip(c, "binlog_format not available")
+	}
+	c.Value = format
+	if image := m.Vars["binlog_row_image"]; image != "" {
+		c.Value += ", row image " + image
+	}
+	if strings.EqualFold(format, "ROW") {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelWarn
+	}
+	return c
+}
+
+func checkSyncBinlog(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Binlog Sync",
+		Threshold:   "N/A",
+		Description: "How often the binary log is flushed to disk (sync_binlog).",
+		Detail: "With sync_binlog=1 the binary log is fsynced at every commit, so a " +
+			"committed transaction is never missing from it after a power loss or OS " +
+			"crash. With 0 the OS decides when to flush, and with N the server fsyncs " +
+			"every N commit groups; up to that many transactions can then be lost " +
+			"from the binary log while InnoDB kept them, leaving replicas and " +
+			"point-in-time recovery inconsistent with the source. The setting is " +
+			"graded by the Durability Profile.",
+	}
+
+	v, ok := m.Vars["sync_binlog"]
+	if !ok {
+		return skip(c, "sync_binlog not available")
+	}
+	c.Value = v
+	c.Level = LevelOK
+	return c
+}
+
+func checkBinlogRetention(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Binlog Retention",
+		Threshold:   "automatic purge OK, none WARN",
+		Description: "How long binary logs are kept before they are purged automatically.",
+		Detail: "binlog_expire_logs_seconds (MySQL 8.0, MariaDB 10.6) or expire_logs_days " +
+			"removes binary logs older than the limit at startup and when the log " +
+			"rotates. With both at 0 nothing is purged and the logs grow until the " +
+			"disk is full, unless a cron job or backup tool runs PURGE BINARY LOGS; " +
+			"binlog_expire_logs_auto_purge=OFF (MySQL 8.0.29+) has the same effect. " +
+			"Keep enough for the slowest replica and back to the last full backup.",
+	}
+
+	secs := varFloat(m, "binlog_expire_logs_seconds")
+	days := varFloat(m, "expire_logs_days")
+	switch {
+	case strings.EqualFold(m.Vars["binlog_expire_logs_auto_purge"], "OFF"):
+		c.Value = "never purged (binlog_expire_logs_auto_purge=OFF)"
+		c.Level = LevelWarn
+		return c
+	case secs > 0:
+		c.Value = fmtDuration(time.Duration(secs)*time.Second) + " (binlog_expire_logs_seconds)"
+	case days > 0:
+		c.Value = fmt.Sprintf("%g days (expire_logs_days)", days)
+	default:
+		c.Value = "never purged"
+		c.Level = LevelWarn
+		return c
+	}
+	c.Level = LevelOK
+	return c
+}
+
+func checkBinlogSize(m *db.MySQL, h *host.Info) Check {
+	c := Check{
+		Name:        "Binlog Size",
+		Threshold:   "< 50% of free space OK, 50-100% WARN, more than free space CRIT",
+		Description: "Total size of the binary logs from SHOW BINARY LOGS against the free space on their filesystem.",
+		Detail: "Binary logs are kept until they expire, so their total is roughly the " +
+			"write volume of the retention period. When it approaches the free space " +
+			"left on the filesystem, a busy period or a stuck purge (an unexpired log " +
+			"still read by a lagging replica or a backup) fills the disk, and the " +
+			"server stops accepting writes.",
+	}
+
+	if reason, missing := missingPrivilege(m, db.ReqReplicationClient); missing {
+		return skip(c, reason)
+	}
+	// SHOW BINARY LOGS holds the binary log lock while it stats every file.
+	if reason, busy := m.Overloaded(); busy {
+		return skip(c, reason)
+	}
+	rows, err := m.QueryRows("SHOW BINARY LOGS")
+	if err != nil {
+		return skip(c, fmt.Sprintf("query failed: %v", err))
+	}
+	var total float64
+	for _, r := range rows {
+		size, _ := strconv.ParseFloat(r["File_size"], 64)
+		total += size
+	}
+	c.Value = fmt.Sprintf("%s in %d files", fmtBytes(total), len(rows))
+
+	base := m.Vars["log_bin_basename"]
+	if _, skipped := h.HostChecksSkipped(); skipped || base == "" {
+		c.Level = LevelOK
+		return c
+	}
+	u, err := readFSUsage(filepath.Dir(base))
+	if err != nil {
+		c.Level = LevelOK
+		return c
+	}
+	c.Value += fmt.Sprintf(", %s free", fmtBytes(float64(u.avail)))
+	v, ok := pct(total, float64(u.avail))
+	switch {
+	case !ok || v > 100:
+		c.Level = LevelCrit
+	case v >= 50:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+// checkBinlogCacheDiskUse reports how often the transactional ("") or
+// non-transactional ("stmt_") binlog cache spilled to a temporary file.
+func checkBinlogCacheDiskUse(m *db.MySQL, kind string) Check {
+	c := Check{
+		Name:        "Binlog Cache Disk Use",
+		Threshold:   "<= 10% OK, > 10% WARN",
+		Description: "Transactions whose binary log events did not fit in binlog_cache_size.",
+		Detail: "Each session collects the binary log events of its transaction in a " +
+			"binlog_cache_size buffer and writes them to the log at commit. Larger " +
+			"transactions spill to a temporary file in tmpdir, adding disk I/O to " +
+			"every such commit. Raise binlog_cache_size if many transactions spill; " +
+			"very large ones spill regardless.",
+	}
+	if kind == "stmt_" {
+		c.Name = "Binlog Statement Cache Disk Use"
+		c.Description = "Non-transactional statements whose binary log events did not fit in binlog_stmt_cache_size."
+		c.Detail = "Changes to non-transactional tables (MyISAM, MEMORY) are collected " +
+			"in a separate binlog_stmt_cache_size buffer and spill to a temporary file " +
+			"in tmpdir when they do not fit."
+	}
+
+	disk := statusFloat(m, "Binlog_"+kind+"cache_disk_use")
+	total := statusFloat(m, "Binlog_"+kind+"cache_use")
+	v, ok := pct(disk, total)
+	if !ok {
+		c.Value = "not used"
+		c.Level = LevelOK
+		return c
+	}
+	c.Value = fmt.Sprintf("%s (%.0f of %.0f, %s cache)", fmtPct(v), disk, total,
+		fmtBytes(varFloat(m, "binlog_"+kind+"cache_size")))
+	if v <= 10 {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelWarn
+	}
+	return c
+}
@@ -8,14 +8,14 @@ import (
 )
 
 func RunCacheChecks(m *db.MySQL) []Check {
-	var results []Check
-	results = append(results, checkThreadCacheHitRate(m))
-	results = append(results, checkThreadCacheRatio(m))
-	results = append(results, checkTableCacheHitRate(m))
-	results = append(results, checkTableDefCacheHitRate(m))
-	results = append(results, checkTableOpenCacheOverflows(m))
-	results = append(results, checkTableLockingEfficiency(m))
-	return results
+	return runAll(
+		func() Check { return checkThreadCacheHitRate(m) },
+		func() Check { return checkThreadCacheRatio(m) },
+		func() Check { return checkTableCacheHitRate(m) },
+		func() Check { return checkTableDefCacheHitRate(m) },
+		func() Check { return checkTableOpenCacheOverflows(m) },
+		func() Check { return checkTableLockingEfficiency(m) },
+	)
 }
 
 func checkThreadCacheHitRate(m *db.MySQL) Check {
@@ -1,6 +1,12 @@
 package checks
 
-import "fmt"
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+)
 
 type Level int
 
@@ -33,6 +39,7 @@ type Check struct {
 	Threshold   string
 	Description string
 	Detail      string
+	Reason      string // why the check was skipped, if known
 }
 
 type Category struct {
@@ -64,6 +71,47 @@ func OverallLevel(cats []Category) Level {
 	return worst
 }
 
+// runAll runs each check in order, logging its outcome and duration.
+func runAll(fns ...func() Check) []Check {
+	results := make([]Check, 0, len(fns))
+	for _, fn := range fns {
+		results = append(results, runMany(func() []Check { return []Check{fn()} })...)
+	}
+	return results
+}
+
+// runMany runs a check that yields one result per object (e.g. per
+// filesystem). The logged duration covers the whole group.
+func runMany(fn func() []Check) []Check {
+	start := time.Now()
+	results := fn()
+	d := time.Since(start)
+	for _, c := range results {
+		slog.Debug("check", "name", c.Name, "level", c.Level.String(), "value", c.Value,
+			"reason", c.Reason, "duration", d)
+	}
+	return results
+}
+
+// skip marks c as skipped for the given reason.
+func skip(c Check, reason string) Check {
+	c.Value = "N/A"
+	c.Level = LevelSkip
+	c.Reason = reason
+	return c
+}
+
+// missingPrivilege returns the preflight's reason for the first unmet
+// requirement in reqs, if any.
+func missingPrivilege(m *db.MySQL, reqs ...db.Requirement) (string, bool) {
+	for _, r := range reqs {
+		if reason, ok := m.Privileges.Missing(r); ok {
+			return reason, true
+		}
+	}
+	return "", false
+}
+
 func pct(numerator, denominator float64) (float64, bool) {
 	if denominator == 0 {
 		return 0, false
@@ -75,6 +123,21 @@ func fmtPct(v float64) string {
 	return fmt.Sprintf("%.2f%%", v)
 }
 
+func fmtBytes(v float64) string {
+	switch {
+	case v >= 1<<40:
+		return fmt.Sprintf("%.1fTB", v/(1<<40))
+	case v >= 1<<30:
+		return fmt.Sprintf("%.1fGB", v/(1<<30))
+	case v >= 1<<20:
+		return fmt.Sprintf("%.0fMB", v/(1<<20))
+	case v >= 1<<10:
+		return fmt.Sprintf("%.0fKB", v/(1<<10))
+	default:
+		return fmt.Sprintf("%.0fB", v)
+	}
+}
+
 func fmtMin(v float64) string {
 	return fmt.Sprintf("%.0fmin", v)
 }
@@ -0,0 +1,171 @@
+package checks
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+)
+
+// mysqlFilesystem is a filesystem holding one or more of the server's
+// directories.
+type mysqlFilesystem struct {
+	path  string   // first directory found on it, used for statfs
+	mount string   // mount point, or path if it could not be resolved
+	roles []string // what lives there, e.g. "datadir", "binlog"
+}
+
+func (fs mysqlFilesystem) has(role string) bool {
+	for _, r := range fs.roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// mysqlDirectories lists the directories the server writes to, by role,
+// with relative paths resolved against datadir. Log directories are only
+// included when the log is enabled.
+func mysqlDirectories(m *db.MySQL) [][2]string {
+	datadir := m.Vars["datadir"]
+	var dirs [][2]string
+	add := func(role, path string) {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return
+		}
+		if !filepath.IsAbs(path) {
+			if datadir == "" {
+				return
+			}
+			path = filepath.Join(datadir, path)
+		}
+		dirs = append(dirs, [2]string{role, filepath.Clean(path)})
+	}
+	on := func(name string) bool {
+		v := strings.ToUpper(m.Vars[name])
+		return v == "ON" || v == "1"
+	}
+
+	add("datadir", datadir)
+	for _, t := range strings.Split(m.Vars["tmpdir"], ":") {
+		add("tmpdir", t)
+	}
+	add("innodb_tmpdir", m.Vars["innodb_tmpdir"])
+	add("redo log", m.Vars["innodb_log_group_home_dir"])
+	add("undo", m.Vars["innodb_undo_directory"])
+	if on("log_bin") && m.Vars["log_bin_basename"] != "" {
+		add("binlog", filepath.Dir(m.Vars["log_bin_basename"]))
+	}
+	if on("slow_query_log") && m.Vars["slow_query_log_file"] != "" {
+		add("slow log", filepath.Dir(m.Vars["slow_query_log_file"]))
+	}
+	if on("general_log") && m.Vars["general_log_file"] != "" {
+		add("general log", filepath.Dir(m.Vars["general_log_file"]))
+	}
+	if e := m.Vars["log_error"]; e != "" && e != "stderr" {
+		add("error log", filepath.Dir(e))
+	}
+	return dirs
+}
+
+// mysqlFilesystems groups the server's directories by the filesystem they
+// live on, keeping the order of first appearance (datadir first).
+func mysqlFilesystems(m *db.MySQL) []mysqlFilesystem {
+	var result []mysqlFilesystem
+	index := make(map[string]int)
+	for _, d := range mysqlDirectories(m) {
+		role, path := d[0], d[1]
+		key, mountPoint := path, path
+		if mt, err := findMount(path); err == nil {
+			key, mountPoint = mt.device(), mt.mountPoint
+		}
+		if i, ok := index[key]; ok {
+			if !result[i].has(role) {
+				result[i].roles = append(result[i].roles, role)
+			}
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, mysqlFilesystem{path: path, mount: mountPoint, roles: []string{role}})
+	}
+	return result
+}
+
+// fsUsage is the space and inode usage of a filesystem as seen by an
+// unprivileged process such as mysqld: blocks reserved for root are not
+// counted as available.
+type fsUsage struct {
+	size, used, avail  uint64 // bytes; size = used + avail
+	reserved           uint64 // bytes reserved for root
+	inodes, inodesUsed uint64 // 0 inodes if the filesystem doesn't report them
+}
+
+func (u fsUsage) pct() float64 {
+	if u.size == 0 {
+		return 0
+	}
+	return float64(u.used) * 100.0 / float64(u.size)
+}
+
+func (u fsUsage) inodePct() (float64, bool) {
+	return pct(float64(u.inodesUsed), float64(u.inodes))
+}
+
+func checkDiskSpace(m *db.MySQL) []Check {
+	base := Check{
+		Name:      "Disk Space Usage",
+		Threshold: "space and inodes < 80% OK, >= 80% WARN",
+		Description: "Used space and inodes on each filesystem MySQL writes to.",
+		Detail: "Monitors every filesystem holding MySQL data, logs or temporary files: " +
+			"datadir, tmpdir, innodb_tmpdir, redo and undo logs, binary logs and the " +
+			"slow, general and error logs. Running out of space or inodes can cause " +
+			"MySQL to crash, corrupt data, or refuse writes entirely; a full tmpdir " +
+			"fails ALTER TABLE and large sorts even when the datadir has room. Usage " +
+			"is computed like df: blocks reserved for root are not available to mysqld. " +
+			"Keep at least 20% free for operations like ALTER TABLE, binary logs, and " +
+			"temporary files.",
+	}
+
+	filesystems := mysqlFilesystems(m)
+	if len(filesystems) == 0 {
+		return []Check{skip(base, "datadir not known")}
+	}
+
+	var results []Check
+	for _, fs := range filesystems {
+		c := base
+		c.Name = "Disk Space " + fs.mount
+		u, err := readFSUsage(fs.path)
+		if err != nil {
+			results = append(results, skip(c, err.Error()))
+			continue
+		}
+		if u.size == 0 {
+			results = append(results, skip(c, "filesystem reports no blocks"))
+			continue
+		}
+
+		worst := u.pct()
+		c.Value = fmt.Sprintf("%s of %s", fmtPct(u.pct()), fmtBytes(float64(u.size)))
+		if ip, ok := u.inodePct(); ok {
+			c.Value += fmt.Sprintf(", inodes %s", fmtPct(ip))
+			if ip > worst {
+				worst = ip
+			}
+		}
+		if u.reserved > 0 {
+			c.Value += fmt.Sprintf(", %s reserved for root", fmtBytes(float64(u.reserved)))
+		}
+		c.Value += " (" + strings.Join(fs.roles, ", ") + ")"
+		if worst < 80 {
+			c.Level = LevelOK
+		} else {
+			c.Level = LevelWarn
+		}
+		results = append(results, c)
+	}
+	return results
+}
@@ -0,0 +1,118 @@
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// diskStats are the cumulative counters of one /proc/diskstats line.
+type diskStats struct {
+	reads, writes       uint64 // completed requests
+	readMs, writeMs     uint64 // time spent on completed requests
+	ioMs                uint64 // time the device had I/O in flight
+	weightedMs          uint64 // sum of in-flight time over all requests
+	readSect, writeSect uint64
+}
+
+// dataDevice resolves the "major:minor" of the block device holding datadir.
+func dataDevice(m *db.MySQL) (string, error) {
+	datadir := m.Vars["datadir"]
+	if datadir == "" {
+		return "", fmt.Errorf("datadir not known")
+	}
+	mt, err := findMount(datadir)
+	if err != nil {
+		return "", err
+	}
+	if mt.major == 0 {
+		return "", fmt.Errorf("%s is on %s, which has no block device", datadir, mt.fsType)
+	}
+	return mt.device(), nil
+}
+
+// readDiskStats returns the counters for the device with the given
+// "major:minor", along with its kernel name.
+func readDiskStats(dev string) (diskStats, string, error) {
+	var d diskStats
+	data, err := host.ReadFile("/proc/diskstats")
+	if err != nil {
+		return d, "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 14 || f[0]+":"+f[1] != dev {
+			continue
+		}
+		v := make([]uint64, 11)
+		for i := range v {
+			v[i], _ = strconv.ParseUint(f[3+i], 10, 64)
+		}
+		d = diskStats{
+			reads: v[0], readSect: v[2], readMs: v[3],
+			writes: v[4], writeSect: v[6], writeMs: v[7],
+			ioMs: v[9], weightedMs: v[10],
+		}
+		return d, f[2], nil
+	}
+	return d, "", fmt.Errorf("device %s not found in /proc/diskstats", dev)
+}
+
+func checkDataDiskIO(h *host.Info, w *window) Check {
+	c := Check{
+		Name:      "Data Disk I/O",
+		Threshold: "util < 60% and await < 20ms OK, util > 90% or await > 100ms CRIT, else WARN",
+		Description: "IOPS, utilization, average wait and queue depth of the datadir's block device.",
+		Detail: "Sampled from /proc/diskstats over the same window as CPU Utilization. " +
+			"Utilization is the share of time the device had requests in flight; await " +
+			"is the average time a request spent queued and being served; queue depth " +
+			"is the average number of requests in flight. A saturated data disk is the " +
+			"usual root cause of InnoDB pending I/O, dirty page build-up and checkpoint " +
+			"stalls. On SSD/NVMe, which serve many requests in parallel, await is the " +
+			"more reliable signal.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	if w.diskErr != nil {
+		return skip(c, w.diskErr.Error())
+	}
+	if w.before.diskErr != nil {
+		return skip(c, w.before.diskErr.Error())
+	}
+	if w.after.diskErr != nil {
+		return skip(c, w.after.diskErr.Error())
+	}
+	elapsed := w.elapsed()
+	if elapsed <= 0 {
+		return skip(c, "empty sample window")
+	}
+
+	b, a := w.before.disk, w.after.disk
+	ios := float64((a.reads - b.reads) + (a.writes - b.writes))
+	iops := ios / elapsed
+	util := float64(a.ioMs-b.ioMs) / (elapsed * 1000) * 100
+	if util > 100 {
+		util = 100
+	}
+	await := 0.0
+	if ios > 0 {
+		await = float64((a.readMs-b.readMs)+(a.writeMs-b.writeMs)) / ios
+	}
+	queue := float64(a.weightedMs-b.weightedMs) / (elapsed * 1000)
+
+	c.Value = fmt.Sprintf("%s: %.0f IOPS, util %.1f%%, await %.1fms, qd %.1f", w.diskName, iops, util, await, queue)
+	switch {
+	case util > 90 || await > 100:
+		c.Level = LevelCrit
+	case util >= 60 || await >= 20:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
@@ -0,0 +1,253 @@
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+)
+
+// RunDurabilityChecks audits the settings that decide whether committed
+// transactions survive a mysqld, OS or power failure, and summarises them as
+// a durability profile.
+func RunDurabilityChecks(m *db.MySQL, e *Env) []Check {
+	return runMany(func() []Check {
+		replica, unknown := replicaRole(m, e)
+		settings := []Check{
+			checkFlushLogAtCommit(m),
+			checkDoublewrite(m),
+			checkFlushMethod(m),
+			checkRelayLogRecovery(m, replica, unknown),
+			checkGTIDConsistency(m, replica, unknown),
+		}
+		return append([]Check{checkDurabilityProfile(m, settings)}, settings...)
+	})
+}
+
+// replicaRole reports whether m replicates from a source, from the replica
+// status the Replication category also reports. When that cannot be told,
+// unknown gives the reason.
+func replicaRole(m *db.MySQL, e *Env) (replica bool, unknown string) {
+	if reason, missing := missingPrivilege(m, db.ReqReplicationClient); missing {
+		return false, reason
+	}
+	if e.replicaErr != nil {
+		return false, fmt.Sprintf("replica status unknown: %v", e.replicaErr)
+	}
+	return len(e.replicas) > 0, ""
+}
+
+// checkDurabilityProfile rates the server as fully durable, relaxed or
+// unsafe from the individual settings and sync_binlog, listing the settings
+// that are not fully durable.
+func checkDurabilityProfile(m *db.MySQL, settings []Check) Check {
+	c := Check{
+		Name:        "Durability Profile",
+		Threshold:   "fully durable OK, relaxed WARN, unsafe CRIT",
+		Description: "Overall crash safety of the server, with the settings that weaken it.",
+		Detail: "Fully durable: every acknowledged commit survives a crash of mysqld, the " +
+			"OS or the power, and replicas resume without diverging. Relaxed settings " +
+			"trade the last second or so of commits on an OS crash for throughput " +
+			"(innodb_flush_log_at_trx_commit=2, sync_binlog other than 1) or make " +
+			"replicas need manual repair after a crash. Unsafe settings lose commits " +
+			"on a mysqld crash alone or can leave torn, unrecoverable pages; they " +
+			"belong on benchmarks and disposable test servers, not production.",
+	}
+
+	var unsafe, relaxed []string
+	for _, s := range settings {
+		switch s.Level {
+		case LevelCrit:
+			unsafe = append(unsafe, s.Value)
+		case LevelWarn:
+			relaxed = append(relaxed, s.Value)
+		}
+	}
+	if v, ok := m.Vars["sync_binlog"]; ok && v != "1" && strings.EqualFold(m.Vars["log_bin"], "ON") {
+		relaxed = append(relaxed, "sync_binlog="+v+" (an OS crash can drop committed transactions from the binary log)")
+	}
+
+	switch {
+	case len(unsafe) > 0:
+		c.Value = "unsafe: " + strings.Join(unsafe, "; ")
+		if len(relaxed) > 0 {
+			c.Value += "; relaxed: " + strings.Join(relaxed, "; ")
+		}
+		c.Level = LevelCrit
+	case len(relaxed) > 0:
+		c.Value = "relaxed: " + strings.Join(relaxed, "; ")
+		c.Level = LevelWarn
+	default:
+		c.Value = "fully durable"
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkFlushLogAtCommit(m *db.MySQL) Check {
+	c := Check{
+		Name:        "InnoDB Log Flush at Commit",
+		Threshold:   "1 or 3 OK, 2 WARN, 0 CRIT",
+		Description: "When InnoDB writes and syncs the redo log for a commit (innodb_flush_log_at_trx_commit).",
+		Detail: "With 1 the redo log is written and fsynced at every commit, the only " +
+			"setting where a committed transaction survives any crash; MariaDB's 3 " +
+			"behaves the same. With 2 it is " +
+			"written at commit but fsynced once per second, so an OS crash or power " +
+			"loss loses up to a second of commits; with 0 it is also written once per " +
+			"second, so even a mysqld crash loses them.",
+	}
+
+	v, ok := m.Vars["innodb_flush_log_at_trx_commit"]
+	if !ok {
+		return skip(c, "innodb_flush_log_at_trx_commit not available")
+	}
+	c.Value = "innodb_flush_log_at_trx_commit=" + v
+	switch v {
+	case "1", "3":
+		c.Level = LevelOK
+	case "2":
+		c.Value += " (an OS crash loses up to 1s of commits)"
+		c.Level = LevelWarn
+	default:
+		c.Value += " (a mysqld crash loses up to 1s of commits)"
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkDoublewrite(m *db.MySQL) Check {
+	c := Check{
+		Name:        "InnoDB Doublewrite",
+		Threshold:   "ON OK, DETECT_ONLY WARN, OFF CRIT",
+		Description: "Whether InnoDB protects data pages against partial writes (innodb_doublewrite).",
+		Detail: "A page is larger than the unit the disk writes atomically, so a crash " +
+			"in the middle of a page write leaves a torn page that the redo log cannot " +
+			"repair. The doublewrite buffer writes each page to a second location " +
+			"first so it can be restored. DETECT_ONLY (MySQL 8.0.30+) only detects " +
+			"torn pages. Turning it off is only safe on storage that guarantees " +
+			"atomic page writes, such as ZFS or devices used with " +
+			"innodb_use_atomic_writes.",
+	}
+
+	v, ok := m.Vars["innodb_doublewrite"]
+	if !ok {
+		return skip(c, "innodb_doublewrite not available")
+	}
+	c.Value = "innodb_doublewrite=" + v
+	switch strings.ToUpper(v) {
+	case "ON", "1", "DETECT_AND_RECOVER", "FAST":
+		c.Level = LevelOK
+	case "DETECT_ONLY":
+		c.Value += " (torn pages are detected but not repaired)"
+		c.Level = LevelWarn
+	default:
+		c.Value += " (a crash during a page write can corrupt the page)"
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkFlushMethod(m *db.MySQL) Check {
+	c := Check{
+		Name:        "InnoDB Flush Method",
+		Threshold:   "nosync/littlesync CRIT, else OK",
+		Description: "How InnoDB writes and syncs data and log files (innodb_flush_method).",
+		Detail: "O_DIRECT (the default on Linux since MySQL 8.4) bypasses the OS page " +
+			"cache for data files and fsyncs them; fsync and O_DSYNC go through the " +
+			"page cache and double-buffer data but are equally durable. nosync and " +
+			"littlesync skip fsync calls; they exist for internal performance testing " +
+			"and lose data on any OS crash.",
+	}
+
+	v, ok := m.Vars["innodb_flush_method"]
+	if !ok {
+		return skip(c, "innodb_flush_method not available")
+	}
+	if v == "" {
+		v = "fsync"
+	}
+	c.Value = "innodb_flush_method=" + v
+	switch strings.ToLower(v) {
+	case "nosync", "littlesync":
+		c.Value += " (fsync is skipped)"
+		c.Level = LevelCrit
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkRelayLogRecovery(m *db.MySQL, replica bool, unknown string) Check {
+	c := Check{
+		Name:        "Replica Crash Safety",
+		Threshold:   "relay_log_recovery ON with positions in tables OK, else WARN on replicas",
+		Description: "Whether a replica resumes replication correctly after a crash (relay_log_recovery, relay_log_info_repository).",
+		Detail: "After a crash the relay log may be incomplete and a position kept in a " +
+			"file may be behind the transactions already applied. With " +
+			"relay_log_recovery=ON the replica discards its relay logs and fetches " +
+			"again from the last applied position, which is only reliable when that " +
+			"position is committed with the transactions: relay_log_info_repository=TABLE " +
+			"(the only choice from MySQL 8.0.23) or GTID positions. Otherwise a " +
+			"crashed replica re-applies or skips transactions and must be rebuilt.",
+	}
+
+	recovery := m.Vars["relay_log_recovery"]
+	if recovery == "" {
+		return skip(c, "relay_log_recovery not available")
+	}
+	repo := m.Vars["relay_log_info_repository"]
+	c.Value = "relay_log_recovery=" + recovery
+	if repo != "" {
+		c.Value += ", relay_log_info_repository=" + repo
+	}
+	safe := strings.EqualFold(recovery, "ON") && (repo == "" || strings.EqualFold(repo, "TABLE"))
+	switch {
+	case safe:
+		c.Level = LevelOK
+	case unknown != "":
+		return skip(c, unknown)
+	case !replica:
+		c.Value += " (not a replica)"
+		c.Level = LevelOK
+	default:
+		c.Value += " (a crashed replica may re-apply or skip transactions)"
+		c.Level = LevelWarn
+	}
+	return c
+}
+
+func checkGTIDConsistency(m *db.MySQL, replica bool, unknown string) Check {
+	c := Check{
+		Name:        "GTID Consistency",
+		Threshold:   "gtid_mode ON with enforce_gtid_consistency ON OK, else WARN when replicating",
+		Description: "Whether transactions carry global transaction IDs (gtid_mode, enforce_gtid_consistency).",
+		Detail: "With GTIDs each replica records exactly which transactions it has, so " +
+			"after a crash or failover it asks a source for the missing ones " +
+			"(SOURCE_AUTO_POSITION) instead of relying on file positions that may be " +
+			"stale. enforce_gtid_consistency rejects statements that cannot be logged " +
+			"safely as a single GTID transaction.",
+	}
+
+	if m.IsMariaDB() {
+		return skip(c, "MariaDB always assigns GTIDs")
+	}
+	mode := m.Vars["gtid_mode"]
+	if mode == "" {
+		return skip(c, "gtid_mode not available")
+	}
+	enforce := m.Vars["enforce_gtid_consistency"]
+	c.Value = "gtid_mode=" + mode + ", enforce_gtid_consistency=" + enforce
+	switch {
+	case strings.EqualFold(mode, "ON") && strings.EqualFold(enforce, "ON"):
+		c.Level = LevelOK
+	case unknown != "" && !strings.EqualFold(m.Vars["log_bin"], "ON"):
+		return skip(c, unknown)
+	case !replica && !strings.EqualFold(m.Vars["log_bin"], "ON"):
+		c.Value += " (binary log disabled)"
+		c.Level = LevelOK
+	default:
+		c.Value += " (failover relies on binary log file positions)"
+		c.Level = LevelWarn
+	}
+	return c
+}
@@ -8,15 +8,15 @@ import (
 )
 
 func RunEngineChecks(m *db.MySQL) []Check {
-	var results []Check
-	results = append(results, checkMyISAMCacheHitRate(m))
-	results = append(results, checkMyISAMKeyWriteRatio(m))
-	results = append(results, checkInnoDBCacheHitRate(m))
-	results = append(results, checkInnoDBBufferPoolWaitFree(m))
-	results = append(results, checkRedoLogCoverage(m))
-	results = append(results, checkInnoDBDirtyPages(m))
-	results = append(results, checkInnoDBPendingIO(m))
-	return results
+	return runAll(
+		func() Check { return checkMyISAMCacheHitRate(m) },
+		func() Check { return checkMyISAMKeyWriteRatio(m) },
+		func() Check { return checkInnoDBCacheHitRate(m) },
+		func() Check { return checkInnoDBBufferPoolWaitFree(m) },
+		func() Check { return checkRedoLogCoverage(m) },
+		func() Check { return checkInnoDBDirtyPages(m) },
+		func() Check { return checkInnoDBPendingIO(m) },
+	)
 }
 
 func checkMyISAMCacheHitRate(m *db.MySQL) Check {
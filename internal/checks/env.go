@@ -0,0 +1,41 @@
+package checks
+
+import (
+	"errors"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// Env is what one run determines once and several categories read: the
+// mysqld process, the host sample window and the replica status. NewEnv
+// computes it before any category runs, so the categories see the same
+// values whatever order they run in.
+type Env struct {
+	pid    int
+	pidErr error
+	window *window // nil when host checks are skipped
+
+	replicas   []map[string]string
+	replicaErr error
+}
+
+// NewEnv reads the replica status and, unless host checks are skipped,
+// finds the mysqld process and samples the host counters over
+// sampleSeconds.
+func NewEnv(m *db.MySQL, h *host.Info, sampleSeconds int) *Env {
+	e := &Env{}
+	e.replicas, e.replicaErr = replicaStatus(m)
+	if reason, skipped := h.HostChecksSkipped(); skipped {
+		e.pidErr = errors.New(reason)
+		return e
+	}
+	e.pid, e.pidErr = findMysqldPid(m)
+	e.window = sampleWindow(m, e.pid, e.pidErr, sampleSeconds)
+	return e
+}
+
+// mysqldPid returns the pid of the server's process, or why it is unknown.
+func (e *Env) mysqldPid() (int, error) {
+	return e.pid, e.pidErr
+}
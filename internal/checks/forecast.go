@@ -0,0 +1,268 @@
+package checks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+const (
+	historyRetention  = 30 * 24 * time.Hour
+	historyMaxSamples = 2000
+	// forecastWindow is how far back samples are used for the growth rate;
+	// older growth says little about the current workload.
+	forecastWindow = 7 * 24 * time.Hour
+	// minHistorySpan is the shortest history that is preferred over the
+	// sample window.
+	minHistorySpan = time.Hour
+	// minGradeSpan is the shortest history a forecast is graded on. Usage
+	// moves in steps (tablespace extensions, binlog rotation, bursts of
+	// writes), so a shorter span extrapolates noise; such forecasts are
+	// reported as information only.
+	minGradeSpan = 24 * time.Hour
+)
+
+// fsSample is one usage measurement of a filesystem.
+type fsSample struct {
+	At   int64  `json:"t"` // unix seconds
+	Used uint64 `json:"used"`
+	Size uint64 `json:"size"`
+}
+
+// usageHistory is persisted between runs in the -history file, keyed by
+// mount point.
+type usageHistory struct {
+	Filesystems map[string][]fsSample `json:"filesystems"`
+}
+
+// loadHistory reads the history file. A missing file yields an empty
+// history; so does a corrupt one, together with an error, so that the file
+// is replaced when the history is saved.
+func loadHistory(path string) (*usageHistory, error) {
+	data, err := host.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &usageHistory{Filesystems: make(map[string][]fsSample)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := &usageHistory{}
+	if err := json.Unmarshal(data, h); err != nil {
+		return &usageHistory{Filesystems: make(map[string][]fsSample)},
+			fmt.Errorf("corrupt history file %s replaced: %w", path, err)
+	}
+	if h.Filesystems == nil {
+		h.Filesystems = make(map[string][]fsSample)
+	}
+	return h, nil
+}
+
+// save writes the history atomically so that concurrent or interrupted
+// runs cannot leave a truncated file behind.
+func (h *usageHistory) save(path string) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mysql-health-check-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (h *usageHistory) add(mount string, s fsSample) {
+	cutoff := s.At - int64(historyRetention.Seconds())
+	var kept []fsSample
+	for _, old := range h.Filesystems[mount] {
+		if old.At >= cutoff && old.At < s.At {
+			kept = append(kept, old)
+		}
+	}
+	kept = append(kept, s)
+	if len(kept) > historyMaxSamples {
+		kept = kept[len(kept)-historyMaxSamples:]
+	}
+	h.Filesystems[mount] = kept
+}
+
+// growthRate fits a least-squares line through the samples of the last
+// forecastWindow and returns its slope in bytes per second, together with
+// the time span the samples cover.
+func growthRate(samples []fsSample) (float64, time.Duration, bool) {
+	if len(samples) < 2 {
+		return 0, 0, false
+	}
+	cutoff := samples[len(samples)-1].At - int64(forecastWindow.Seconds())
+	var n, sx, sy, sxx, sxy float64
+	first := int64(-1)
+	for _, s := range samples {
+		if s.At < cutoff {
+			continue
+		}
+		if first < 0 {
+			first = s.At
+		}
+		x := float64(s.At - first)
+		y := float64(s.Used)
+		n++
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	span := time.Duration(samples[len(samples)-1].At-first) * time.Second
+	denom := n*sxx - sx*sx
+	if n < 2 || denom == 0 {
+		return 0, span, false
+	}
+	return (n*sxy - sx*sy) / denom, span, true
+}
+
+func checkDiskForecast(m *db.MySQL, w *window, historyPath string) []Check {
+	base := Check{
+		Name:        "Disk Full Forecast",
+		Threshold:   "full in > 30 days OK, 7-30 days WARN, < 7 days CRIT; requires 1 day of -history",
+		Description: "Estimated time until the datadir and binlog filesystems fill up, from their growth rate.",
+		Detail: "Percentage used alone does not tell whether a filesystem is in danger: a " +
+			"90% full 10TB volume growing 1GB/day has years left, a 60% full volume " +
+			"growing 50GB/day may have days. The growth rate is fitted over the last " +
+			"7 days of measurements kept in the -history file. Usage grows in steps " +
+			"(tablespace extensions, binlog writes), so the check is skipped until the " +
+			"history spans a day; the estimate from the sample window or the shorter " +
+			"history is given in the reason. The time until the Disk Space WARN " +
+			"threshold (80%) is shown as well.",
+	}
+
+	var hist *usageHistory
+	var histProblems []string
+	if historyPath != "" {
+		var err error
+		if hist, err = loadHistory(historyPath); err != nil {
+			histProblems = append(histProblems, err.Error())
+		}
+	}
+
+	var results []Check
+	for _, fsys := range mysqlFilesystems(m) {
+		if !fsys.has("datadir") && !fsys.has("binlog") {
+			continue
+		}
+		c := base
+		c.Name = "Disk Full Forecast " + fsys.mount
+		u, err := readFSUsage(fsys.path)
+		if err != nil {
+			results = append(results, skip(c, err.Error()))
+			continue
+		}
+		if u.size == 0 {
+			results = append(results, skip(c, "filesystem reports no blocks"))
+			continue
+		}
+
+		var rate float64
+		var source string
+		ok, graded := false, false
+		notGraded := "requires -history"
+		if hist != nil {
+			notGraded = "-history spans less than 1 day"
+			hist.add(fsys.mount, fsSample{At: time.Now().Unix(), Used: u.used, Size: u.size})
+			var span time.Duration
+			if rate, span, ok = growthRate(hist.Filesystems[fsys.mount]); ok && span >= minHistorySpan {
+				source = fmt.Sprintf("over %.1fd", span.Hours()/24)
+				graded = span >= minGradeSpan
+			} else {
+				ok = false
+			}
+		}
+		if !ok {
+			before, hasBefore := w.before.fsUsed[fsys.mount]
+			after, hasAfter := w.after.fsUsed[fsys.mount]
+			if hasBefore && hasAfter && w.elapsed() > 0 {
+				rate = (float64(after) - float64(before)) / w.elapsed()
+				source = fmt.Sprintf("over %.0fs", w.elapsed())
+				ok = true
+			}
+		}
+		if !ok {
+			results = append(results, skip(c, "no usage samples"))
+			continue
+		}
+
+		perDay := rate * 86400
+		if perDay <= 0 {
+			c.Value = fmt.Sprintf("not growing (%s)", source)
+			c.Level = LevelOK
+			if !graded {
+				c = skip(c, notGraded+"; estimate: "+c.Value)
+			}
+			results = append(results, c)
+			continue
+		}
+		daysTo := func(p float64) float64 {
+			target := float64(u.size) * p / 100
+			if float64(u.used) >= target {
+				return 0
+			}
+			return (target - float64(u.used)) / perDay
+		}
+		full := daysTo(100)
+		warn := "reached"
+		if d := daysTo(80); d > 0 {
+			warn = fmt.Sprintf("in %.1fd", d)
+		}
+		c.Value = fmt.Sprintf("full in %.1fd, 80%% %s (+%s/day %s)", full, warn, fmtBytes(perDay), source)
+		switch {
+		case !graded:
+			c = skip(c, notGraded+"; estimate: "+c.Value)
+		case full > 30:
+			c.Level = LevelOK
+		case full >= 7:
+			c.Level = LevelWarn
+		default:
+			c.Level = LevelCrit
+		}
+		results = append(results, c)
+	}
+
+	if hist != nil {
+		if err := hist.save(historyPath); err != nil {
+			histProblems = append(histProblems, fmt.Sprintf("cannot write history file: %v", err))
+		}
+	}
+	if len(histProblems) > 0 {
+		results = append(results, checkHistoryFile(histProblems))
+	}
+	return results
+}
+
+// checkHistoryFile reports problems reading or writing the -history file,
+// which would otherwise keep the forecast on the sample window unnoticed.
+func checkHistoryFile(problems []string) Check {
+	return Check{
+		Name:        "Disk Usage History",
+		Threshold:   "readable and writable OK, else WARN",
+		Description: "The -history file the disk-full forecast keeps between runs.",
+		Detail: "Without a readable and writable history file the forecast cannot be " +
+			"graded. Check the file's directory and permissions; a corrupt file is " +
+			"replaced with a new history.",
+		Value: strings.Join(problems, "; "),
+		Level: LevelWarn,
+	}
+}
@@ -0,0 +1,65 @@
+package checks
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGrowthRate(t *testing.T) {
+	const day = int64(24 * time.Hour / time.Second)
+	const gb = 1 << 30
+	tests := []struct {
+		name     string
+		samples  []fsSample
+		wantRate float64 // bytes per second
+		wantSpan time.Duration
+		wantOK   bool
+	}{
+		{"no samples", nil, 0, 0, false},
+		{"one sample", []fsSample{{At: 0, Used: gb}}, 0, 0, false},
+		{
+			"steady growth",
+			[]fsSample{{At: 0, Used: 10 * gb}, {At: day, Used: 11 * gb}, {At: 2 * day, Used: 12 * gb}},
+			float64(gb) / float64(day), 48 * time.Hour, true,
+		},
+		{
+			"shrinking",
+			[]fsSample{{At: 0, Used: 12 * gb}, {At: day, Used: 10 * gb}},
+			-2 * float64(gb) / float64(day), 24 * time.Hour, true,
+		},
+		{
+			"flat",
+			[]fsSample{{At: 0, Used: gb}, {At: 3600, Used: gb}, {At: 7200, Used: gb}},
+			0, 2 * time.Hour, true,
+		},
+		{
+			"noisy growth is fitted",
+			[]fsSample{{At: 0, Used: 100}, {At: 10, Used: 300}, {At: 20, Used: 200}, {At: 30, Used: 400}},
+			8, 30 * time.Second, true,
+		},
+		{
+			"samples older than the window are ignored",
+			[]fsSample{
+				{At: 0, Used: 50 * gb},
+				{At: 10 * day, Used: 10 * gb},
+				{At: 11 * day, Used: 11 * gb},
+			},
+			float64(gb) / float64(day), 24 * time.Hour, true,
+		},
+		{
+			"same timestamp",
+			[]fsSample{{At: day, Used: gb}, {At: day, Used: 2 * gb}},
+			0, 0, false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, span, ok := growthRate(tt.samples)
+			if ok != tt.wantOK || span != tt.wantSpan || math.Abs(rate-tt.wantRate) > 1e-9*math.Max(1, math.Abs(tt.wantRate)) {
+				t.Errorf("growthRate() = %g, %s, %v, want %g, %s, %v",
+					rate, span, ok, tt.wantRate, tt.wantSpan, tt.wantOK)
+			}
+		})
+	}
+}
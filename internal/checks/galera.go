@@ -0,0 +1,244 @@
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+)
+
+// RunGaleraChecks reports the wsrep state of a Galera cluster node
+// (MariaDB Galera Cluster, Percona XtraDB Cluster). It returns nothing on
+// servers without wsrep. MariaDB lists a few wsrep status variables even
+// with wsrep_on=OFF, so the variable decides.
+func RunGaleraChecks(m *db.MySQL) []Check {
+	if !strings.EqualFold(m.Vars["wsrep_on"], "ON") {
+		return nil
+	}
+	return runAll(
+		func() Check { return checkGaleraClusterStatus(m) },
+		func() Check { return checkGaleraReady(m) },
+		func() Check { return checkGaleraClusterSize(m) },
+		func() Check { return checkGaleraLocalState(m) },
+		func() Check { return checkGaleraFlowControl(m) },
+		func() Check { return checkGaleraQueue(m, "recv") },
+		func() Check { return checkGaleraQueue(m, "send") },
+		func() Check { return checkGaleraCertFailures(m) },
+	)
+}
+
+func checkGaleraClusterStatus(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Galera Cluster Status",
+		Threshold:   "Primary OK, else CRIT",
+		Description: "Whether this node is part of the primary component (wsrep_cluster_status).",
+		Detail: "Only nodes in the Primary component accept queries. A node in a " +
+			"Non-Primary component lost contact with the majority of the cluster (split " +
+			"brain protection) and refuses reads and writes until it rejoins; " +
+			"Disconnected means it is not connected to any cluster.",
+	}
+
+	status := m.Status["wsrep_cluster_status"]
+	c.Value = status
+	if status == "Primary" {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkGaleraReady(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Galera Node Ready",
+		Threshold:   "wsrep_ready ON and wsrep_connected ON OK, else CRIT",
+		Description: "Whether the node accepts queries (wsrep_ready) and is connected to the group (wsrep_connected).",
+		Detail: "wsrep_ready is OFF while the node is not in the primary component or is " +
+			"still joining; queries then fail with \"WSREP has not yet prepared node for " +
+			"application use\". Load balancers should route away from such nodes.",
+	}
+
+	ready, ok := m.Status["wsrep_ready"]
+	if !ok {
+		return skip(c, "wsrep_ready not available")
+	}
+	connected := m.Status["wsrep_connected"]
+	c.Value = fmt.Sprintf("ready %s, connected %s", ready, connected)
+	if strings.EqualFold(ready, "ON") && strings.EqualFold(connected, "ON") {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkGaleraClusterSize(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Galera Cluster Size",
+		Threshold:   "all configured nodes and >= 3 OK, fewer WARN, 1 of several CRIT",
+		Description: "Nodes in the cluster (wsrep_cluster_size) against the nodes in wsrep_cluster_address.",
+		Detail: "A Galera cluster needs a majority to stay Primary, so it takes three " +
+			"nodes (or two plus an arbitrator) to survive the loss of one. A size below " +
+			"the number of nodes in wsrep_cluster_address means nodes have left or " +
+			"crashed; the remaining ones carry their load and the next failure may " +
+			"cost the quorum.",
+	}
+
+	size := int(statusFloat(m, "wsrep_cluster_size"))
+	if size == 0 {
+		return skip(c, "wsrep_cluster_size not available")
+	}
+	expected := 0
+	if addr, ok := strings.CutPrefix(m.Vars["wsrep_cluster_address"], "gcomm://"); ok {
+		addr, _, _ = strings.Cut(addr, "?")
+		for _, node := range strings.Split(addr, ",") {
+			if strings.TrimSpace(node) != "" {
+				expected++
+			}
+		}
+	}
+
+	c.Value = fmt.Sprintf("%d nodes", size)
+	if expected > 0 {
+		c.Value += fmt.Sprintf(" of %d configured", expected)
+	}
+	switch {
+	case size == 1 && expected > 1:
+		c.Level = LevelCrit
+	case size < 3 || size < expected:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkGaleraLocalState(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Galera Local State",
+		Threshold:   "Synced OK, Donor/Desynced/Joining/Joined WARN, else CRIT",
+		Description: "Replication state of this node (wsrep_local_state_comment).",
+		Detail: "Synced nodes apply writes as they are certified. A Donor is sending a " +
+			"state transfer to a joining node and may be blocked or slowed during it; " +
+			"Desynced nodes were taken out of flow control on purpose (e.g. for a " +
+			"backup) and can fall behind; Joining and Joined nodes are still receiving " +
+			"or applying a state transfer and hold stale data.",
+	}
+
+	state := m.Status["wsrep_local_state_comment"]
+	if state == "" {
+		return skip(c, "wsrep_local_state_comment not available")
+	}
+	c.Value = state
+	switch {
+	case state == "Synced":
+		c.Level = LevelOK
+	case strings.HasPrefix(state, "Donor"), state == "Desynced", state == "Joining", state == "Joined":
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkGaleraFlowControl(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Galera Flow Control",
+		Threshold:   "< 5% paused OK, 5-20% WARN, > 20% CRIT",
+		Description: "Share of time replication was paused by flow control (wsrep_flow_control_paused).",
+		Detail: "When a node's receive queue exceeds gcs.fc_limit it asks the whole " +
+			"cluster to pause, so one slow node stalls writes everywhere. " +
+			"wsrep_flow_control_sent counts pauses this node requested, " +
+			"wsrep_flow_control_recv those it obeyed; a high sent count identifies the " +
+			"node holding the cluster back. The paused fraction covers the time since " +
+			"the status was last read or flushed.",
+	}
+
+	if _, ok := m.Status["wsrep_flow_control_paused"]; !ok {
+		return skip(c, "wsrep_flow_control_paused not available")
+	}
+	paused := statusFloat(m, "wsrep_flow_control_paused") * 100
+	c.Value = fmt.Sprintf("%s paused (sent %s, received %s)", fmtPct(paused),
+		m.Status["wsrep_flow_control_sent"], m.Status["wsrep_flow_control_recv"])
+	switch {
+	case paused < 5:
+		c.Level = LevelOK
+	case paused <= 20:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+// checkGaleraQueue reports the average receive ("recv") or send ("send")
+// queue length.
+func checkGaleraQueue(m *db.MySQL, dir string) Check {
+	c := Check{
+		Name:        "Galera Receive Queue",
+		Threshold:   "avg < 0.5 OK, 0.5-5 WARN, > 5 CRIT",
+		Description: "Average length of the queue of write sets waiting to be applied (wsrep_local_recv_queue_avg).",
+		Detail: "A receive queue that does not drain means this node applies write sets " +
+			"slower than the cluster produces them; once it reaches gcs.fc_limit the " +
+			"node triggers flow control and slows the whole cluster. Raising " +
+			"wsrep_slave_threads (wsrep_applier_threads) or fixing slow storage helps.",
+	}
+	if dir == "send" {
+		c.Name = "Galera Send Queue"
+		c.Description = "Average length of the queue of write sets waiting to be sent (wsrep_local_send_queue_avg)."
+		c.Detail = "A send queue that does not drain means this node's writes cannot be " +
+			"replicated as fast as they are made, usually because of network " +
+			"bandwidth or latency to the other nodes. Commits on this node wait for " +
+			"their write sets to be sent."
+	}
+
+	key := "wsrep_local_" + dir + "_queue_avg"
+	if _, ok := m.Status[key]; !ok {
+		return skip(c, key+" not available")
+	}
+	avg := statusFloat(m, key)
+	c.Value = fmt.Sprintf("avg %.2f, max %s", avg, m.Status["wsrep_local_"+dir+"_queue_max"])
+	switch {
+	case avg < 0.5:
+		c.Level = LevelOK
+	case avg <= 5:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkGaleraCertFailures(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Galera Certification Failures",
+		Threshold:   "< 1% OK, 1-5% WARN, > 5% CRIT",
+		Description: "Local transactions that failed certification or were aborted by replicated ones.",
+		Detail: "Galera certifies each transaction against concurrent writes on other " +
+			"nodes at commit time. Conflicts fail certification " +
+			"(wsrep_local_cert_failures) or abort a local transaction in favour of a " +
+			"replicated one (wsrep_local_bf_aborts); the client gets a deadlock error " +
+			"and must retry. Frequent conflicts mean the same rows are written on " +
+			"several nodes; send writes for a data set to a single node.",
+	}
+
+	commits := statusFloat(m, "wsrep_local_commits")
+	failures := statusFloat(m, "wsrep_local_cert_failures")
+	aborts := statusFloat(m, "wsrep_local_bf_aborts")
+	v, ok := pct(failures+aborts, commits+failures+aborts)
+	if !ok {
+		c.Value = "no local commits"
+		c.Level = LevelOK
+		return c
+	}
+	c.Value = fmt.Sprintf("%s (%.0f cert failures, %.0f BF aborts, %.0f commits)", fmtPct(v), failures, aborts, commits)
+	switch {
+	case v < 1:
+		c.Level = LevelOK
+	case v <= 5:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
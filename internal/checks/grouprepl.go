@@ -0,0 +1,313 @@
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+)
+
+// groupReplicationActive reports whether the group_replication plugin is
+// loaded and configured, as on InnoDB Cluster members.
+func groupReplicationActive(m *db.MySQL) bool {
+	return m.Vars["group_replication_group_name"] != ""
+}
+
+// groupMember is one row of replication_group_members joined with its
+// replication_group_member_stats row.
+type groupMember struct {
+	id, host, port, state, role string
+	certQueue, applierQueue     int64
+}
+
+func (g groupMember) addr() string {
+	return g.host + ":" + g.port
+}
+
+// groupReplicationChecks reports group membership, quorum and queues when
+// the server is a Group Replication member. It returns nothing otherwise.
+func groupReplicationChecks(m *db.MySQL) []Check {
+	if !groupReplicationActive(m) && !m.DryRun() {
+		return nil
+	}
+	return runMany(func() []Check {
+		members, err := loadGroupMembers(m)
+		return []Check{
+			checkGroupMembers(m, members, err),
+			checkGroupQuorum(members, err),
+			checkGroupPrimaryMode(m, members, err),
+			checkGroupQueue(m, members, err, "certification"),
+			checkGroupQueue(m, members, err, "applier"),
+			checkGroupFlowControl(m),
+		}
+	})
+}
+
+func loadGroupMembers(m *db.MySQL) ([]groupMember, error) {
+	if reason, missing := missingPrivilege(m, db.ReqPerformanceSchema); missing {
+		return nil, fmt.Errorf("%s", reason)
+	}
+	if reason, busy := m.Overloaded(); busy {
+		return nil, fmt.Errorf("%s", reason)
+	}
+	rows, err := m.QueryRows("SELECT * FROM performance_schema.replication_group_members")
+	// The stats table lists only the local member in 5.7 and every member
+	// from 8.0.
+	stats, statsErr := m.QueryRows("SELECT * FROM performance_schema.replication_group_member_stats")
+	if err == nil {
+		err = statsErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	byID := make(map[string]map[string]string, len(stats))
+	for _, s := range stats {
+		byID[s["MEMBER_ID"]] = s
+	}
+
+	members := make([]groupMember, 0, len(rows))
+	for _, r := range rows {
+		g := groupMember{
+			id:    r["MEMBER_ID"],
+			host:  r["MEMBER_HOST"],
+			port:  r["MEMBER_PORT"],
+			state: r["MEMBER_STATE"],
+			role:  r["MEMBER_ROLE"],
+		}
+		if s, ok := byID[g.id]; ok {
+			g.certQueue, _ = strconv.ParseInt(s["COUNT_TRANSACTIONS_IN_QUEUE"], 10, 64)
+			g.applierQueue, _ = strconv.ParseInt(s["COUNT_TRANSACTIONS_REMOTE_IN_APPLIER_QUEUE"], 10, 64)
+		}
+		members = append(members, g)
+	}
+	return members, nil
+}
+
+func checkGroupMembers(m *db.MySQL, members []groupMember, err error) Check {
+	c := Check{
+		Name:        "Group Members",
+		Threshold:   "all ONLINE OK, RECOVERING/OFFLINE WARN, ERROR/UNREACHABLE or local member not ONLINE CRIT",
+		Description: "State of each member of the replication group.",
+		Detail: "Members are ONLINE when they take part in the group. RECOVERING members " +
+			"are catching up through distributed recovery and cannot serve consistent " +
+			"reads yet. ERROR members left the group after a failure (often an applier " +
+			"error or diverged data) and need manual rejoin; UNREACHABLE members are " +
+			"not responding and count against the quorum until expelled.",
+	}
+
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	if len(members) == 0 {
+		return skip(c, "no group members listed")
+	}
+
+	online := 0
+	worst := LevelOK
+	localOnline := false
+	var others []string
+	for _, g := range members {
+		switch g.state {
+		case "ONLINE":
+			online++
+		case "RECOVERING", "OFFLINE":
+			worst = max(worst, LevelWarn)
+			others = append(others, g.addr()+" "+g.state)
+		default:
+			worst = LevelCrit
+			others = append(others, g.addr()+" "+g.state)
+		}
+		if g.id == m.Vars["server_uuid"] && g.state == "ONLINE" {
+			localOnline = true
+		}
+	}
+	if !localOnline {
+		worst = LevelCrit
+	}
+
+	c.Value = fmt.Sprintf("%d of %d ONLINE", online, len(members))
+	if len(others) > 0 {
+		c.Value += " (" + strings.Join(others, ", ") + ")"
+	}
+	c.Level = worst
+	return c
+}
+
+func checkGroupQuorum(members []groupMember, err error) Check {
+	c := Check{
+		Name:        "Group Quorum",
+		Threshold:   "majority ONLINE and >= 3 members OK, fewer than 3 WARN, no majority CRIT",
+		Description: "Whether a majority of the group is reachable, and how many failures it can tolerate.",
+		Detail: "A group commits transactions only while a majority of its members can " +
+			"communicate. A group of n members tolerates (n-1)/2 failures, so a group " +
+			"of two has no more fault tolerance than a single server. When the " +
+			"majority is lost the remaining members block writes until quorum is " +
+			"restored or forced with group_replication_force_members.",
+	}
+
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	if len(members) == 0 {
+		return skip(c, "no group members listed")
+	}
+	reachable := 0
+	for _, g := range members {
+		if g.state != "UNREACHABLE" && g.state != "ERROR" && g.state != "OFFLINE" {
+			reachable++
+		}
+	}
+	total := len(members)
+	c.Value = fmt.Sprintf("%d of %d reachable, tolerates %d failure(s)", reachable, total, (total-1)/2)
+	switch {
+	case reachable*2 <= total:
+		c.Level = LevelCrit
+	case total < 3:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkGroupPrimaryMode(m *db.MySQL, members []groupMember, err error) Check {
+	c := Check{
+		Name:        "Group Primary Mode",
+		Threshold:   "single-primary with a primary, or multi-primary with update-everywhere checks OK, else WARN",
+		Description: "Single- or multi-primary mode and the current primary.",
+		Detail: "InnoDB Cluster runs in single-primary mode by default: one member " +
+			"accepts writes and the others are read-only. In multi-primary mode every " +
+			"member accepts writes, and group_replication_enforce_update_everywhere_checks " +
+			"should be ON to reject statements that are unsafe when conflicting " +
+			"writes are certified on different members.",
+	}
+
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	single := !strings.EqualFold(m.Vars["group_replication_single_primary_mode"], "OFF")
+	if !single {
+		c.Value = "multi-primary"
+		if strings.EqualFold(m.Vars["group_replication_enforce_update_everywhere_checks"], "ON") {
+			c.Level = LevelOK
+		} else {
+			c.Value += " without enforce_update_everywhere_checks"
+			c.Level = LevelWarn
+		}
+		return c
+	}
+
+	primary := ""
+	for _, g := range members {
+		if g.role == "PRIMARY" {
+			primary = g.addr()
+		}
+	}
+	// Before 8.0 there is no MEMBER_ROLE column; the primary's UUID is a
+	// status variable.
+	if uuid := m.Status["group_replication_primary_member"]; primary == "" && uuid != "" {
+		for _, g := range members {
+			if g.id == uuid {
+				primary = g.addr()
+			}
+		}
+	}
+	if primary == "" {
+		c.Value = "single-primary, no primary elected"
+		c.Level = LevelWarn
+		return c
+	}
+	c.Value = "single-primary, primary " + primary
+	c.Level = LevelOK
+	return c
+}
+
+// checkGroupQueue reports the deepest certification or applier queue in
+// the group against the flow control threshold for it.
+func checkGroupQueue(m *db.MySQL, members []groupMember, err error, kind string) Check {
+	c := Check{
+		Name:        "Group " + strings.ToUpper(kind[:1]) + kind[1:] + " Queue",
+		Threshold:   "< 50% of the flow control threshold OK, 50-100% WARN, >= 100% CRIT",
+		Description: "Transactions waiting for " + kind + " on the most backlogged member.",
+		Detail: "Every transaction is certified for conflicts on each member and then " +
+			"applied by members other than its origin. Growing queues mean a member " +
+			"cannot keep up; once a queue exceeds " +
+			"group_replication_flow_control_certifier_threshold or " +
+			"_applier_threshold (25000 by default), flow control throttles writes on " +
+			"the whole group to let it catch up.",
+	}
+
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	varName := "group_replication_flow_control_certifier_threshold"
+	if kind == "applier" {
+		varName = "group_replication_flow_control_applier_threshold"
+	}
+	threshold := varFloat(m, varName)
+	if threshold == 0 {
+		threshold = 25000
+	}
+
+	var worst groupMember
+	var depth int64 = -1
+	for _, g := range members {
+		q := g.certQueue
+		if kind == "applier" {
+			q = g.applierQueue
+		}
+		if q > depth {
+			worst, depth = g, q
+		}
+	}
+	if depth < 0 {
+		return skip(c, "no member statistics")
+	}
+
+	v := float64(depth) * 100 / threshold
+	c.Value = fmt.Sprintf("%d on %s (threshold %.0f)", depth, worst.addr(), threshold)
+	switch {
+	case v < 50:
+		c.Level = LevelOK
+	case v < 100:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkGroupFlowControl(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Group Flow Control",
+		Threshold:   "not throttling OK, throttling WARN",
+		Description: "Whether flow control is throttling writes to let slow members catch up.",
+		Detail: "With group_replication_flow_control_mode=QUOTA, writers are limited when " +
+			"any member's queues exceed their thresholds. Throttling shows up as higher " +
+			"commit latency across the group. MySQL 8.0.30 and later count throttling " +
+			"periods in the Gr_flow_control_throttle_* status variables; on older " +
+			"versions only the mode is shown.",
+	}
+
+	mode := m.Vars["group_replication_flow_control_mode"]
+	if mode == "" {
+		return skip(c, "group_replication_flow_control_mode not available")
+	}
+	c.Value = "mode " + mode
+	if strings.EqualFold(mode, "DISABLED") {
+		c.Level = LevelOK
+		return c
+	}
+	count, hasCount := m.Status["Gr_flow_control_throttle_count"]
+	if hasCount {
+		c.Value += fmt.Sprintf(", throttled %s times", count)
+	}
+	if active, _ := strconv.ParseInt(m.Status["Gr_flow_control_throttle_active_count"], 10, 64); active > 0 {
+		c.Value += ", throttling now"
+		c.Level = LevelWarn
+		return c
+	}
+	c.Level = LevelOK
+	return c
+}
@@ -0,0 +1,86 @@
+package checks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gtidSet is a MySQL GTID set: per source UUID (and tag, in 8.3+), the
+// sorted, non-overlapping transaction number intervals.
+type gtidSet map[string][][2]int64
+
+// parseGTIDSet parses sets such as "3E11FA47-...:1-5:11-18,2174B383-...:1-3".
+// Whitespace, including the newlines the server inserts, is ignored.
+func parseGTIDSet(s string) (gtidSet, error) {
+	set := make(gtidSet)
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return set, nil
+	}
+	for _, member := range strings.Split(s, ",") {
+		parts := strings.Split(member, ":")
+		key := strings.ToLower(parts[0])
+		for _, p := range parts[1:] {
+			lo, hi, isRange := strings.Cut(p, "-")
+			a, err := strconv.ParseInt(lo, 10, 64)
+			if err != nil {
+				// A tag ("uuid:tag:1-5") starts a new group for the same UUID.
+				key = strings.ToLower(parts[0]) + ":" + p
+				continue
+			}
+			b := a
+			if isRange {
+				if b, err = strconv.ParseInt(hi, 10, 64); err != nil || b < a {
+					return nil, fmt.Errorf("invalid GTID interval %q", p)
+				}
+			}
+			set[key] = append(set[key], [2]int64{a, b})
+		}
+	}
+	for k, ivs := range set {
+		set[k] = mergeIntervals(ivs)
+	}
+	return set, nil
+}
+
+func mergeIntervals(ivs [][2]int64) [][2]int64 {
+	sort.Slice(ivs, func(i, j int) bool { return ivs[i][0] < ivs[j][0] })
+	var merged [][2]int64
+	for _, iv := range ivs {
+		if n := len(merged); n > 0 && iv[0] <= merged[n-1][1]+1 {
+			merged[n-1][1] = max(merged[n-1][1], iv[1])
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// minus returns the number of transactions in g that are not in o.
+func (g gtidSet) minus(o gtidSet) int64 {
+	var n int64
+	for key, ivs := range g {
+		for _, iv := range ivs {
+			n += iv[1] - iv[0] + 1
+			for _, ov := range o[key] {
+				lo, hi := max(iv[0], ov[0]), min(iv[1], ov[1])
+				if lo <= hi {
+					n -= hi - lo + 1
+				}
+			}
+		}
+	}
+	return n
+}
+
+// holes returns the number of gaps between intervals of the same source,
+// i.e. transactions that were skipped or never arrived.
+func (g gtidSet) holes() int {
+	n := 0
+	for _, ivs := range g {
+		n += len(ivs) - 1
+	}
+	return n
+}
@@ -0,0 +1,133 @@
+package checks
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	uuidA = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	uuidB = "2174b383-5441-11e8-b90a-c80aa9429562"
+)
+
+func TestParseGTIDSet(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want gtidSet
+	}{
+		{"empty", "", gtidSet{}},
+		{"single interval", uuidA + ":1-5", gtidSet{uuidA: {{1, 5}}}},
+		{"single transaction", uuidA + ":7", gtidSet{uuidA: {{7, 7}}}},
+		{"upper case UUID", "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5", gtidSet{uuidA: {{1, 5}}}},
+		{
+			"multiple UUIDs with server line breaks",
+			uuidA + ":1-5:11-18,\n" + uuidB + ":1-3",
+			gtidSet{uuidA: {{1, 5}, {11, 18}}, uuidB: {{1, 3}}},
+		},
+		{"overlapping intervals", uuidA + ":1-5:3-8:10", gtidSet{uuidA: {{1, 8}, {10, 10}}}},
+		{"adjacent intervals", uuidA + ":6-7:1-5", gtidSet{uuidA: {{1, 7}}}},
+		{"same UUID twice", uuidA + ":1-5," + uuidA + ":4-9", gtidSet{uuidA: {{1, 9}}}},
+		{
+			"tagged",
+			uuidA + ":1-3:tag_1:1-5:7",
+			gtidSet{uuidA: {{1, 3}}, uuidA + ":tag_1": {{1, 5}, {7, 7}}},
+		},
+		{
+			"tag before untagged intervals",
+			uuidA + ":tag_1:1-5," + uuidA + ":1-2",
+			gtidSet{uuidA: {{1, 2}}, uuidA + ":tag_1": {{1, 5}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGTIDSet(tt.in)
+			if err != nil {
+				t.Fatalf("parseGTIDSet(%q): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGTIDSet(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGTIDSetInvalid(t *testing.T) {
+	for _, in := range []string{
+		uuidA + ":5-3",
+		uuidA + ":1-x",
+		uuidA + ":1-",
+	} {
+		if got, err := parseGTIDSet(in); err == nil {
+			t.Errorf("parseGTIDSet(%q) = %v, want error", in, got)
+		}
+	}
+}
+
+func TestGTIDSetMinus(t *testing.T) {
+	tests := []struct {
+		name string
+		g, o string
+		want int64
+	}{
+		{"equal", uuidA + ":1-10", uuidA + ":1-10", 0},
+		{"empty minus empty", "", "", 0},
+		{"nothing applied", uuidA + ":1-10", "", 10},
+		{"missing tail", uuidA + ":1-10", uuidA + ":1-5", 5},
+		{"superset", uuidA + ":1-5", uuidA + ":1-10", 0},
+		{"missing middle", uuidA + ":1-10", uuidA + ":1-3:8-10", 4},
+		{
+			"multiple UUIDs",
+			uuidA + ":1-10," + uuidB + ":1-20",
+			uuidA + ":1-10," + uuidB + ":1-15",
+			5,
+		},
+		{"other UUID only", uuidA + ":1-10", uuidB + ":1-10", 10},
+		{"extra UUID in other", uuidA + ":1-10", uuidA + ":1-10," + uuidB + ":1-3", 0},
+		{"overlapping intervals in input", uuidA + ":1-6:4-10", uuidA + ":1-2:2-5", 5},
+		{"tag counted separately", uuidA + ":1-5:tag_1:1-5", uuidA + ":1-5", 5},
+		{"tag applied", uuidA + ":1-5:tag_1:1-5", uuidA + ":tag_1:1-5," + uuidA + ":1-5", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := parseGTIDSet(tt.g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o, err := parseGTIDSet(tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := g.minus(o); got != tt.want {
+				t.Errorf("%q minus %q = %d, want %d", tt.g, tt.o, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGTIDSetHoles(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"empty", "", 0},
+		{"contiguous", uuidA + ":1-10", 0},
+		{"one hole", uuidA + ":1-5:11-18", 1},
+		{"holes across UUIDs", uuidA + ":1-5:11-18," + uuidB + ":1-3:5:9-10", 3},
+		{"overlap is not a hole", uuidA + ":1-5:3-8", 0},
+		{"adjacent is not a hole", uuidA + ":1-5:6-8", 0},
+		{"tags are separate sequences", uuidA + ":1-5:tag_1:8-10", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := parseGTIDSet(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := g.holes(); got != tt.want {
+				t.Errorf("holes(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/config"
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// RemoteReason returns why the server m is connected to does not run on
+// this host, or "" when it does. Host checks must not be run against a
+// remote server: they would measure the monitoring machine instead.
+func RemoteReason(m *db.MySQL, cfg *config.MySQLConfig) string {
+	if service := managedService(m); service != "" {
+		return service + " does not expose the database host"
+	}
+	if cfg.Socket != "" {
+		return ""
+	}
+	h := cfg.Host
+	if h == "" || strings.EqualFold(h, "localhost") {
+		return ""
+	}
+	addrs, err := net.LookupIP(h)
+	if err != nil {
+		return fmt.Sprintf("cannot resolve %s: %v", h, err)
+	}
+	local := localAddrs()
+	for _, ip := range addrs {
+		if ip.IsLoopback() || local[ip.String()] {
+			return ""
+		}
+	}
+	return fmt.Sprintf("connected to %s, which is not an address of this host", h)
+}
+
+// managedService recognises hosted MySQL services from their server
+// variables.
+func managedService(m *db.MySQL) string {
+	switch {
+	case m.Vars["aurora_version"] != "":
+		return "Amazon Aurora"
+	case strings.HasPrefix(m.Vars["basedir"], "/rdsdbbin/"):
+		return "Amazon RDS"
+	case hasVarPrefix(m, "cloudsql_"):
+		return "Google Cloud SQL"
+	case hasVarPrefix(m, "azure_"):
+		return "Azure Database for MySQL"
+	}
+	return ""
+}
+
+func hasVarPrefix(m *db.MySQL, prefix string) bool {
+	for k := range m.Vars {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func localAddrs() map[string]bool {
+	local := make(map[string]bool)
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return local
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok {
+			local[n.IP.String()] = true
+		}
+	}
+	return local
+}
+
+// checkRemoteHost stands in for a category's host checks when the server
+// is not on this host, so the report says why they are missing.
+func checkRemoteHost(h *host.Info) Check {
+	c := Check{
+		Name:        "Host Checks",
+		Threshold:   "N/A",
+		Description: "Checks that read /proc and /sys of the database host.",
+		Detail: "CPU, memory, disk, kernel and network checks read the local /proc and " +
+			"/sys. When the server runs on another machine or as a managed service " +
+			"they would describe the monitoring host instead, so they are skipped. " +
+			"Run the tool on the database host itself, or use -host-checks=on if the " +
+			"detection is wrong.",
+	}
+	reason, _ := h.HostChecksSkipped()
+	return skip(c, reason)
+}
+
+func checkSQLMemory(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Server Memory (performance_schema)",
+		Threshold:   "<= 1.5x buffer pool or < 1GB overhead OK, else WARN",
+		Description: "Memory allocated by the server according to its own instrumentation.",
+		Detail: "When the server is not on this host its resident memory cannot be read " +
+			"from /proc. performance_schema memory instrumentation (enabled by default " +
+			"in MySQL 8.0) counts what the server has allocated, which is read from " +
+			"sys.x$memory_global_total or summed from " +
+			"performance_schema.memory_summary_global_by_event_name. Allocations far " +
+			"beyond the buffer pool point at per-connection buffers, temporary tables " +
+			"or memory leaks; on managed services they are what gets the instance " +
+			"restarted for running out of memory.",
+	}
+
+	if reason, missing := missingPrivilege(m, db.ReqPerformanceSchema); missing {
+		return skip(c, reason)
+	}
+	// Summing the memory summary walks every instrument.
+	if reason, busy := m.Overloaded(); busy {
+		return skip(c, reason)
+	}
+	val, err := m.QueryScalar("SELECT total_allocated FROM sys.`x$memory_global_total`")
+	if err != nil {
+		val, err = m.QueryScalar("SELECT SUM(CURRENT_NUMBER_OF_BYTES_USED) " +
+			"FROM performance_schema.memory_summary_global_by_event_name")
+	}
+	if err != nil {
+		return skip(c, fmt.Sprintf("query failed: %v", err))
+	}
+	var allocated float64
+	if _, err := fmt.Sscan(val, &allocated); err != nil || allocated == 0 {
+		return skip(c, "memory instrumentation is disabled")
+	}
+
+	bufferPool := varFloat(m, "innodb_buffer_pool_size")
+	c.Value = fmt.Sprintf("%s allocated (buffer pool %s)", fmtBytes(allocated), fmtBytes(bufferPool))
+	if allocated <= 1.5*bufferPool || allocated-bufferPool < 1<<30 {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelWarn
+	}
+	return c
+}
+
+func checkSQLDataSize(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Data Size (information_schema)",
+		Threshold:   "free space in tablespaces < 25% or < 1GB OK, else WARN",
+		Description: "Size of all tables and the unused space inside their tablespaces.",
+		Detail: "When the datadir is not on this host its filesystem cannot be checked. " +
+			"information_schema.TABLES gives the size of data and indexes, and " +
+			"DATA_FREE the space allocated to tablespaces but not in use, e.g. after " +
+			"large deletes; tables in a shared tablespace (ibdata1, general " +
+			"tablespaces) all report that tablespace's free space, so it is counted " +
+			"once per tablespace. Managed services bill for allocated storage, and that space " +
+			"is only returned by rebuilding the table (OPTIMIZE TABLE).",
+	}
+
+	if reason, missing := missingPrivilege(m, db.ReqInformationSchema); missing {
+		return skip(c, reason)
+	}
+	// Summing TABLES opens every table's metadata; leave it out while the
+	// server is busy.
+	if reason, busy := m.Overloaded(); busy {
+		return skip(c, reason)
+	}
+	// DATA_FREE is counted once per InnoDB tablespace, which needs the
+	// space IDs (PROCESS). INNODB_TABLES was INNODB_SYS_TABLES before MySQL
+	// 8.0 and on MariaDB.
+	reason, missing := missingPrivilege(m, db.ReqProcess)
+	var rows []map[string]string
+	err := errors.New(reason)
+	if !missing {
+		for _, spaces := range []string{"INNODB_TABLES", "INNODB_SYS_TABLES"} {
+			if rows, err = m.QueryRows(dataSizeQuery(spaces)); err == nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		noFree := fmt.Sprintf("free space not counted: %v", err)
+		rows, err = m.QueryRows("SELECT SUM(DATA_LENGTH + INDEX_LENGTH) AS used " +
+			"FROM information_schema.TABLES " +
+			"WHERE TABLE_SCHEMA NOT IN ('information_schema', 'performance_schema', 'sys')")
+		if err != nil {
+			return skip(c, fmt.Sprintf("query failed: %v", err))
+		}
+		if len(rows) == 0 {
+			return skip(c, "no rows returned")
+		}
+		var used float64
+		fmt.Sscan(rows[0]["used"], &used)
+		c.Value = fmt.Sprintf("%s data and indexes (%s)", fmtBytes(used), noFree)
+		c.Level = LevelOK
+		return c
+	}
+	if len(rows) == 0 {
+		return skip(c, "no rows returned")
+	}
+	var used, free float64
+	fmt.Sscan(rows[0]["used"], &used)
+	fmt.Sscan(rows[0]["free"], &free)
+
+	c.Value = fmt.Sprintf("%s data and indexes, %s free in tablespaces", fmtBytes(used), fmtBytes(free))
+	if v, ok := pct(free, used+free); ok && v >= 25 && free >= 1<<30 {
+		c.Level = LevelWarn
+	} else {
+		c.Level = LevelOK
+	}
+	return c
+}
+
+// dataSizeQuery sums the size of all tables and the free space of their
+// tablespaces, grouping InnoDB tables by the space ID in
+// information_schema.<spaces>. Tables it cannot match, such as other engines
+// or partitions, count on their own.
+func dataSizeQuery(spaces string) string {
+	return "SELECT SUM(used) AS used, SUM(free) AS free FROM (" +
+		"SELECT SUM(t.DATA_LENGTH + t.INDEX_LENGTH) AS used, MAX(t.DATA_FREE) AS free " +
+		"FROM information_schema.TABLES t " +
+		"LEFT JOIN information_schema." + spaces + " s " +
+		"ON CAST(s.NAME AS BINARY) = CAST(CONCAT(t.TABLE_SCHEMA, '/', t.TABLE_NAME) AS BINARY) " +
+		"WHERE t.TABLE_SCHEMA NOT IN ('information_schema', 'performance_schema', 'sys') " +
+		"GROUP BY COALESCE(CAST(s.SPACE AS CHAR), CONCAT(t.TABLE_SCHEMA, '.', t.TABLE_NAME))" +
+		") AS tablespaces"
+}
@@ -0,0 +1,113 @@
+package checks
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// mount is one entry of /proc/self/mountinfo.
+type mount struct {
+	major, minor int
+	mountPoint   string
+	options      string // per-mount options, e.g. "rw,noatime"
+	fsType       string
+	source       string
+	superOptions string // filesystem options, e.g. "rw,barrier=0"
+}
+
+func (mt mount) device() string {
+	return fmt.Sprintf("%d:%d", mt.major, mt.minor)
+}
+
+func readMounts() ([]mount, error) {
+	data, err := host.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	return parseMountinfo(string(data)), nil
+}
+
+func parseMountinfo(data string) []mount {
+	var mounts []mount
+	for _, line := range strings.Split(data, "\n") {
+		pre, post, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(pre)
+		g := strings.Fields(post)
+		if len(f) < 6 || len(g) < 2 {
+			continue
+		}
+		majStr, minStr, ok := strings.Cut(f[2], ":")
+		if !ok {
+			continue
+		}
+		mt := mount{
+			mountPoint: unescapeMount(f[4]),
+			options:    f[5],
+			fsType:     g[0],
+			source:     g[1],
+		}
+		mt.major, _ = strconv.Atoi(majStr)
+		mt.minor, _ = strconv.Atoi(minStr)
+		if len(g) > 2 {
+			mt.superOptions = g[2]
+		}
+		mounts = append(mounts, mt)
+	}
+	return mounts
+}
+
+// findMount returns the mount that contains path, i.e. the one with the
+// longest mount point that is a prefix of the resolved path. Later entries
+// win on ties, since they are mounted on top.
+func findMount(path string) (mount, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	path = filepath.Clean(path)
+
+	mounts, err := readMounts()
+	if err != nil {
+		return mount{}, err
+	}
+	best := -1
+	for i, mt := range mounts {
+		mp := mt.mountPoint
+		if path != mp && mp != "/" && !strings.HasPrefix(path, mp+"/") {
+			continue
+		}
+		if best < 0 || len(mp) >= len(mounts[best].mountPoint) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return mount{}, fmt.Errorf("no mount found for %s", path)
+	}
+	return mounts[best], nil
+}
+
+// unescapeMount decodes the octal escapes (\040 for space etc.) used in
+// mountinfo paths.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
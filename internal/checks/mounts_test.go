@@ -0,0 +1,74 @@
+package checks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMountinfo(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []mount
+	}{
+		{
+			"root and data",
+			"22 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw,errors=remount-ro\n" +
+				"30 22 253:0 / /var/lib/mysql rw,noatime shared:12 - xfs /dev/mapper/vg-mysql rw,attr2,inode64,noquota\n",
+			[]mount{
+				{259, 2, "/", "rw,relatime", "ext4", "/dev/nvme0n1p2", "rw,errors=remount-ro"},
+				{253, 0, "/var/lib/mysql", "rw,noatime", "xfs", "/dev/mapper/vg-mysql", "rw,attr2,inode64,noquota"},
+			},
+		},
+		{
+			"no optional fields",
+			"40 22 0:35 / /run rw,nosuid,nodev - tmpfs tmpfs rw,size=811872k,mode=755\n",
+			[]mount{{0, 35, "/run", "rw,nosuid,nodev", "tmpfs", "tmpfs", "rw,size=811872k,mode=755"}},
+		},
+		{
+			"several optional fields",
+			"50 22 8:17 / /data rw shared:5 master:3 - ext4 /dev/sdb1 rw\n",
+			[]mount{{8, 17, "/data", "rw", "ext4", "/dev/sdb1", "rw"}},
+		},
+		{
+			"escaped mount point",
+			"60 22 8:33 / /mnt/my\\040data rw - ext4 /dev/sdc1 rw\n",
+			[]mount{{8, 33, "/mnt/my data", "rw", "ext4", "/dev/sdc1", "rw"}},
+		},
+		{
+			"missing super options",
+			"70 22 0:50 / /proc rw - proc proc\n",
+			[]mount{{0, 50, "/proc", "rw", "proc", "proc", ""}},
+		},
+		{
+			"malformed lines are skipped",
+			"garbage\n80 22 8:1 / /x rw ext4 /dev/sda1 rw\n90 22 bad / /y rw - ext4 /dev/sda2 rw\n\n",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMountinfo(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMountinfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnescapeMount(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/var/lib/mysql", "/var/lib/mysql"},
+		{`/mnt/a\040b`, "/mnt/a b"},
+		{`/mnt/tab\011x\134y`, "/mnt/tab\tx\\y"},
+		{`/mnt/trailing\04`, `/mnt/trailing\04`},
+		{`/mnt/not\999octal`, `/mnt/not\999octal`},
+	}
+	for _, tt := range tests {
+		if got := unescapeMount(tt.in); got != tt.want {
+			t.Errorf("unescapeMount(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package checks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// RunNetworkChecks inspects the TCP stack of mysqld's network namespace.
+// Counter rates use the same sample window as the System checks.
+func RunNetworkChecks(m *db.MySQL, h *host.Info, e *Env) []Check {
+	if _, skipped := h.HostChecksSkipped(); skipped {
+		return runAll(func() Check { return checkRemoteHost(h) })
+	}
+	w := e.window
+	return runAll(
+		func() Check { return checkListenOverflows(h, w) },
+		func() Check { return checkRetransmits(h, w) },
+		func() Check { return checkListenBacklog(m, h, w) },
+		func() Check { return checkPortConnections(m, h, w) },
+	)
+}
+
+// netDelta returns the increase of a netstat/snmp counter over the window
+// and its value since boot.
+func (w *window) netDelta(key string) (delta, total int64, err error) {
+	if w.before.netErr != nil {
+		return 0, 0, w.before.netErr
+	}
+	if w.after.netErr != nil {
+		return 0, 0, w.after.netErr
+	}
+	total, ok := w.after.net[key]
+	if !ok {
+		return 0, 0, fmt.Errorf("counter %s not found", key)
+	}
+	return total - w.before.net[key], total, nil
+}
+
+func checkListenOverflows(h *host.Info, w *window) Check {
+	c := Check{
+		Name:        "Listen Queue Overflows",
+		Threshold:   "none during the sample OK, any WARN",
+		Description: "TCP connection attempts dropped by the kernel before being accepted (ListenOverflows, ListenDrops).",
+		Detail: "When clients connect faster than mysqld accepts them, for example when an " +
+			"application restarts and opens its whole pool at once, the kernel's accept " +
+			"queue fills up and further connection attempts are dropped. Clients see " +
+			"timeouts or retries after 1s, 3s, 7s; mysqld never sees the attempts, so " +
+			"they do not show up in Connection Utilization or Aborted_connects. " +
+			"ListenOverflows counts the attempts dropped because the accept queue was " +
+			"full; ListenDrops includes those and other drops of incoming connections, " +
+			"and is what is graded. The counters cover every listener in the network " +
+			"namespace, not only mysqld, so compare with Listen Backlog to see whether " +
+			"the MySQL port is the one overflowing; the counts since boot are shown " +
+			"for context.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	drops, dropsTotal, err := w.netDelta("TcpExt.ListenDrops")
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	overflows, overflowsTotal, err := w.netDelta("TcpExt.ListenOverflows")
+	if err != nil {
+		return skip(c, err.Error())
+	}
+
+	c.Value = fmt.Sprintf("%d overflows, %d drops in %.0fs, namespace-wide (%d and %d since boot)",
+		overflows, drops, w.elapsed(), overflowsTotal, dropsTotal)
+	if drops > 0 {
+		c.Level = LevelWarn
+	} else {
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkRetransmits(h *host.Info, w *window) Check {
+	c := Check{
+		Name:        "TCP Retransmits",
+		Threshold:   "< 1% OK, 1-5% WARN, > 5% CRIT",
+		Description: "Share of sent TCP segments that were retransmissions.",
+		Detail: "Retransmissions mean packets were lost between the server and its " +
+			"clients or replicas: a saturated link, a faulty NIC or switch port, or " +
+			"overloaded virtual networking. Each loss adds at least one round-trip " +
+			"timeout (200ms or more) to the affected query. The rate is taken over the " +
+			"sample window, or since boot when nothing was sent during it.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	retrans, retransTotal, err := w.netDelta("Tcp.RetransSegs")
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	out, outTotal, err := w.netDelta("Tcp.OutSegs")
+	if err != nil {
+		return skip(c, err.Error())
+	}
+
+	period := fmt.Sprintf("in %.0fs", w.elapsed())
+	if out <= 0 {
+		retrans, out, period = retransTotal, outTotal, "since boot"
+	}
+	v, ok := pct(float64(retrans), float64(out))
+	if !ok {
+		return skip(c, "no TCP segments sent")
+	}
+
+	c.Value = fmt.Sprintf("%s (%d of %d segments %s)", fmtPct(v), retrans, out, period)
+	switch {
+	case v < 1:
+		c.Level = LevelOK
+	case v <= 5:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkListenBacklog(m *db.MySQL, h *host.Info, w *window) Check {
+	c := Check{
+		Name:        "Listen Backlog",
+		Threshold:   "back_log not capped and queue < 50% OK, capped or queue 50-90% WARN, > 90% CRIT",
+		Description: "Effective listen backlog of the MySQL port and how full its accept queue is.",
+		Detail: "mysqld asks for a listen queue of back_log connections, but the kernel " +
+			"silently caps it at net.core.somaxconn. When the cap is lower, bursts of " +
+			"new connections overflow the queue sooner than the configuration suggests. " +
+			"The current queue length is read from the listening socket in " +
+			"/proc/<pid>/net/tcp; raise somaxconn to at least back_log.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	port, reason, ok := tcpPort(m)
+	if !ok {
+		return skip(c, reason)
+	}
+	backLog := int64(varFloat(m, "back_log"))
+	if backLog == 0 {
+		return skip(c, "back_log not available")
+	}
+	socks, err := readTCPSockets(w.netDir)
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	var queued uint64
+	listening := false
+	for _, s := range socks {
+		if s.state == tcpListen && s.localPort == port {
+			listening = true
+			queued = max(queued, s.rxQueue)
+		}
+	}
+	if !listening {
+		return skip(c, fmt.Sprintf("no listener on port %d in %s", port, w.netDir))
+	}
+
+	// somaxconn is per network namespace and /proc/sys shows our own; it
+	// matches mysqld's unless mysqld runs in a separate namespace.
+	effective := backLog
+	capped := false
+	somaxconn, err := readSysctlInt("net/core/somaxconn")
+	if err == nil && somaxconn < backLog {
+		effective = somaxconn
+		capped = true
+	}
+
+	v := float64(queued) * 100.0 / float64(effective)
+	c.Value = fmt.Sprintf("%d queued of %d (back_log %d", queued, effective, backLog)
+	if err == nil {
+		c.Value += fmt.Sprintf(", somaxconn %d", somaxconn)
+	}
+	c.Value += ")"
+	switch {
+	case v > 90:
+		c.Level = LevelCrit
+	case v >= 50 || capped:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkPortConnections(m *db.MySQL, h *host.Info, w *window) Check {
+	c := Check{
+		Name:        "MySQL Port Connections",
+		Threshold:   "CLOSE_WAIT < 10% and SYN_RECV < 50% of back_log OK, else WARN",
+		Description: "TCP connections to the MySQL port by state, with the busiest clients.",
+		Detail: "Groups the sockets on the MySQL port from /proc/<pid>/net/tcp{,6} by TCP " +
+			"state and client address. Many CLOSE_WAIT sockets mean clients hung up but " +
+			"mysqld has not closed the connection yet (threads stuck, or thread cache " +
+			"issues); many SYN_RECV sockets mean handshakes are arriving faster than " +
+			"they complete. The client list shows which application hosts hold most " +
+			"connections.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	port, reason, ok := tcpPort(m)
+	if !ok {
+		return skip(c, reason)
+	}
+	socks, err := readTCPSockets(w.netDir)
+	if err != nil {
+		return skip(c, err.Error())
+	}
+
+	states := make(map[int]int)
+	clients := make(map[string]int)
+	total := 0
+	for _, s := range socks {
+		if s.localPort != port || s.state == tcpListen {
+			continue
+		}
+		total++
+		states[s.state]++
+		ip := s.remoteIP
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		clients[ip.String()]++
+	}
+	if total == 0 {
+		c.Value = fmt.Sprintf("no TCP connections on port %d", port)
+		c.Level = LevelOK
+		return c
+	}
+
+	c.Value = fmt.Sprintf("%d (%s); top clients: %s", total, fmtCounts(states), topClients(clients, 3))
+	backLog := varFloat(m, "back_log")
+	switch {
+	case states[tcpCloseWait] >= 10 && float64(states[tcpCloseWait]) >= 0.1*float64(total),
+		backLog > 0 && float64(states[tcpSynRecv]) >= 0.5*backLog:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+// tcpPort returns the port mysqld listens on, or why TCP checks do not
+// apply.
+func tcpPort(m *db.MySQL) (int, string, bool) {
+	if strings.EqualFold(m.Vars["skip_networking"], "ON") {
+		return 0, "skip_networking is ON", false
+	}
+	port, err := strconv.Atoi(m.Vars["port"])
+	if err != nil || port <= 0 {
+		return 0, "port not known", false
+	}
+	return port, "", true
+}
+
+// fmtCounts formats socket counts per state, most frequent first.
+func fmtCounts(states map[int]int) string {
+	keys := make([]int, 0, len(states))
+	for k := range states {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if states[keys[i]] != states[keys[j]] {
+			return states[keys[i]] > states[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		name, ok := tcpStateNames[k]
+		if !ok {
+			name = fmt.Sprintf("0x%02X", k)
+		}
+		parts[i] = fmt.Sprintf("%s %d", name, states[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// topClients returns the n addresses with the most connections.
+func topClients(clients map[string]int, n int) string {
+	addrs := make([]string, 0, len(clients))
+	for a := range clients {
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		if clients[addrs[i]] != clients[addrs[j]] {
+			return clients[addrs[i]] > clients[addrs[j]]
+		}
+		return addrs[i] < addrs[j]
+	})
+	if len(addrs) > n {
+		addrs = addrs[:n]
+	}
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		parts[i] = fmt.Sprintf("%s %d", a, clients[a])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,383 @@
+package checks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+const (
+	oomLookback = 7 * 24 * time.Hour
+	// shortUptime is the uptime below which a restart is reported; counters
+	// collected over less time say little about the workload.
+	shortUptime = time.Hour
+	// oomMatchTolerance is how far apart the journal and /dev/kmsg times of
+	// one kill may be. /dev/kmsg times are derived from the boot time and
+	// drift a little from the wall clock.
+	oomMatchTolerance = 5 * time.Second
+)
+
+// oomKilledRe matches the kernel's "Out of memory: Killed process 1234
+// (mysqld)" and "Memory cgroup out of memory: Killed process ..." lines.
+var oomKilledRe = regexp.MustCompile(`Killed process (\d+) \(([^)]+)\)`)
+
+// oomKills caches the kernel log scan; both the OOM and uptime checks use
+// it and reading the journal can be slow.
+var oomKills = sync.OnceValues(recentOOMKills)
+
+// oomKill is a kill of a MySQL server process found in the kernel log.
+type oomKill struct {
+	at   time.Time
+	pid  int
+	comm string
+}
+
+// oomScan is the result of searching the kernel log for OOM kills. since
+// is the oldest time the logs that could be read go back to, at most
+// oomLookback ago: the ring buffer in /dev/kmsg only reaches back to the
+// last boot or wrap, and a volatile journal to the last boot.
+type oomScan struct {
+	kills []oomKill
+	since time.Time
+}
+
+// period describes the time the scan covers, e.g. "in the last 7 days" or
+// "since 2024-05-01 10:00".
+func (s oomScan) period() string {
+	if time.Since(s.since) >= oomLookback-time.Hour {
+		return "in the last 7 days"
+	}
+	return "since " + s.since.Format("2006-01-02 15:04")
+}
+
+func checkOOMScoreAdj(h *host.Info, e *Env) Check {
+	c := Check{
+		Name:        "mysqld OOM Score",
+		Threshold:   "oom_score_adj <= 0 OK, > 0 WARN",
+		Description: "How readily the kernel's OOM killer picks mysqld.",
+		Detail: "When the host or cgroup runs out of memory the kernel kills the process " +
+			"with the highest oom_score, which is mostly its memory footprint; on a " +
+			"database host that is nearly always mysqld. oom_score_adj shifts the score " +
+			"from -1000 (never kill) to 1000. A positive value makes mysqld an even more " +
+			"likely victim; a negative one (OOMScoreAdjust= in the systemd unit) lets " +
+			"smaller processes be killed first.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	pid, err := e.mysqldPid()
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	adj, err := readIntFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid))
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	c.Value = fmt.Sprintf("oom_score_adj=%d", adj)
+	if score, err := readIntFile(fmt.Sprintf("/proc/%d/oom_score", pid)); err == nil {
+		c.Value += fmt.Sprintf(", oom_score=%d", score)
+	}
+	if adj > 0 {
+		c.Level = LevelWarn
+	} else {
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkOOMKills(h *host.Info) Check {
+	c := Check{
+		Name:        "mysqld OOM Kills",
+		Threshold:   "none in 7 days (or as far as the kernel log goes back) OK, within 7 days WARN, within 24 hours CRIT",
+		Description: "Recent kills of mysqld or mariadbd by the kernel's OOM killer.",
+		Detail: "A memory warning is only a risk; an OOM kill is the outcome. Kernel " +
+			"messages are read from the systemd journal and from /dev/kmsg (requires " +
+			"root, or kernel.dmesg_restrict=0). The ring buffer in /dev/kmsg is lost " +
+			"on reboot, so when the journal is not available or not persistent the " +
+			"period actually covered is shown. After a kill mysqld restarts with a " +
+			"cold buffer pool and runs crash recovery; repeated kills mean the memory " +
+			"configuration (buffer pool, per-connection buffers) exceeds what the host " +
+			"or cgroup allows.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	scan, err := oomKills()
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	if len(scan.kills) == 0 {
+		c.Value = "none " + scan.period()
+		c.Level = LevelOK
+		return c
+	}
+	last := scan.kills[len(scan.kills)-1]
+	c.Value = fmt.Sprintf("%d %s, last %s (%s pid %d)",
+		len(scan.kills), scan.period(), last.at.Format("2006-01-02 15:04"), last.comm, last.pid)
+	if time.Since(last.at) < 24*time.Hour {
+		c.Level = LevelCrit
+	} else {
+		c.Level = LevelWarn
+	}
+	return c
+}
+
+func checkUptime(m *db.MySQL, h *host.Info) Check {
+	c := Check{
+		Name:        "Server Uptime",
+		Threshold:   ">= 1 hour OK, < 1 hour WARN, < 1 hour after an OOM kill CRIT",
+		Description: "Time since mysqld started, to catch unexpected restarts.",
+		Detail: "A server that started recently was restarted, upgraded, crashed or " +
+			"killed, and all ratios computed from counters since startup (hit rates, " +
+			"temporary tables on disk, etc.) are based on too little traffic to be " +
+			"meaningful. The host's own uptime tells a reboot from a restart of mysqld " +
+			"alone, and a kernel OOM kill shortly before startup identifies the cause.",
+	}
+
+	raw, ok := m.Status["Uptime"]
+	if !ok {
+		return skip(c, "Uptime status not available")
+	}
+	secs, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return skip(c, "invalid Uptime "+raw)
+	}
+	uptime := time.Duration(secs) * time.Second
+	c.Value = fmtDuration(uptime)
+	if uptime >= shortUptime {
+		c.Level = LevelOK
+		return c
+	}
+
+	c.Level = LevelWarn
+	if _, bad := h.ProcUnreliable(); bad {
+		return c
+	}
+	started := time.Now().Add(-uptime)
+	if hostUp, err := hostUptime(); err == nil && hostUp-uptime < 10*time.Minute {
+		c.Value += " (host rebooted)"
+	}
+	if scan, err := oomKills(); err == nil {
+		for _, k := range scan.kills {
+			if k.at.Before(started.Add(time.Minute)) && started.Sub(k.at) < 10*time.Minute {
+				c.Value += ", restarted after OOM kill at " + k.at.Format("15:04:05")
+				c.Level = LevelCrit
+			}
+		}
+	}
+	return c
+}
+
+// recentOOMKills returns the OOM kills of server processes in the last
+// oomLookback, oldest first. The journal and /dev/kmsg are merged: the
+// journal may reach back across reboots, while /dev/kmsg has the latest
+// messages even where journald does not store kernel messages.
+func recentOOMKills() (oomScan, error) {
+	journal, jerr := readKernelJournal()
+	kmsg, kerr := readKmsg()
+	if jerr != nil && kerr != nil {
+		return oomScan{}, fmt.Errorf("kernel log not readable: %v; journal: %v", kerr, jerr)
+	}
+
+	return mergeOOMKills(time.Now(), journal, kmsg), nil
+}
+
+// mergeOOMKills collects the OOM kills of server processes after
+// now-oomLookback from each source of kernel lines, oldest first.
+func mergeOOMKills(now time.Time, sources ...[]kernelLine) oomScan {
+	cutoff := now.Add(-oomLookback)
+	scan := oomScan{since: now}
+	for _, lines := range sources {
+		if len(lines) > 0 && lines[0].at.Before(scan.since) {
+			scan.since = lines[0].at
+		}
+		for _, l := range lines {
+			match := oomKilledRe.FindStringSubmatch(l.msg)
+			if match == nil || !serverComms[match[2]] || l.at.Before(cutoff) {
+				continue
+			}
+			pid, _ := strconv.Atoi(match[1])
+			scan.add(oomKill{at: l.at, pid: pid, comm: match[2]})
+		}
+	}
+	if scan.since.Before(cutoff) {
+		scan.since = cutoff
+	}
+	sort.Slice(scan.kills, func(i, j int) bool { return scan.kills[i].at.Before(scan.kills[j].at) })
+	return scan
+}
+
+// add records k unless it is already known. Both sources report the same
+// kill with slightly different times, so a kill matches on pid and process
+// name within oomMatchTolerance; the pid alone is not enough, as mysqld
+// often gets the same pid again when it is restarted early at boot.
+func (s *oomScan) add(k oomKill) {
+	for _, o := range s.kills {
+		if o.pid == k.pid && o.comm == k.comm && o.at.Sub(k.at).Abs() <= oomMatchTolerance {
+			return
+		}
+	}
+	s.kills = append(s.kills, k)
+}
+
+type kernelLine struct {
+	at  time.Time
+	msg string
+}
+
+// readKmsg reads the kernel ring buffer from /dev/kmsg without blocking.
+// Each read returns one "prio,seq,usec,flags;message" record; timestamps are
+// microseconds since boot.
+func readKmsg() ([]kernelLine, error) {
+	start := time.Now()
+	fd, err := syscall.Open("/dev/kmsg", syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		slog.Debug("read kmsg", "err", err)
+		return nil, fmt.Errorf("/dev/kmsg: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	boot, err := bootTime()
+	if err != nil {
+		return nil, err
+	}
+	var lines []kernelLine
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if errors.Is(err, syscall.EPIPE) {
+			// Records were overwritten while reading; continue with the next.
+			continue
+		}
+		if err != nil || n <= 0 {
+			break
+		}
+		header, msg, ok := bytes.Cut(buf[:n], []byte(";"))
+		if !ok {
+			continue
+		}
+		fields := strings.Split(string(header), ",")
+		if len(fields) < 3 {
+			continue
+		}
+		usec, _ := strconv.ParseInt(fields[2], 10, 64)
+		msg, _, _ = bytes.Cut(msg, []byte("\n"))
+		lines = append(lines, kernelLine{
+			at:  boot.Add(time.Duration(usec) * time.Microsecond),
+			msg: string(msg),
+		})
+	}
+	slog.Debug("read kmsg", "records", len(lines), "duration", time.Since(start))
+	return lines, nil
+}
+
+// readKernelJournal returns the kernel messages of the last oomLookback
+// from the systemd journal, which survives reboots and ring buffer wrap.
+func readKernelJournal() ([]kernelLine, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	since := fmt.Sprintf("-%dh", int(oomLookback.Hours()))
+	out, err := exec.CommandContext(ctx, "journalctl", "-k", "-q", "--no-pager",
+		"-o", "short-unix", "--since", since).Output()
+	slog.Debug("read journal", "bytes", len(out), "duration", time.Since(start), "err", err)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []kernelLine
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		// "1697520000.123456 host kernel: message"
+		ts, rest, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		secs, err := strconv.ParseFloat(ts, 64)
+		if err != nil {
+			continue
+		}
+		if _, msg, ok := strings.Cut(rest, "kernel: "); ok {
+			rest = msg
+		}
+		lines = append(lines, kernelLine{
+			at:  time.Unix(0, int64(secs*1e9)),
+			msg: rest,
+		})
+	}
+	return lines, nil
+}
+
+// bootTime returns when the host booted, from btime in /proc/stat.
+func bootTime() (time.Time, error) {
+	data, err := host.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "btime "); ok {
+			secs, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(secs, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no btime in /proc/stat")
+}
+
+func hostUptime() (time.Duration, error) {
+	data, err := host.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty /proc/uptime")
+	}
+	secs, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+func readIntFile(path string) (int64, error) {
+	data, err := host.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// fmtDuration formats d as e.g. "3d 4h", "2h 5m" or "12m".
+func fmtDuration(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	mins := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, mins)
+	default:
+		return fmt.Sprintf("%dm", mins)
+	}
+}
@@ -0,0 +1,71 @@
+package checks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeOOMKills(t *testing.T) {
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration) time.Time { return now.Add(-ago) }
+	kill := func(ago time.Duration, pid, comm string) kernelLine {
+		return kernelLine{at: at(ago), msg: "Out of memory: Killed process " + pid + " (" + comm + ") total-vm:1kB"}
+	}
+
+	tests := []struct {
+		name    string
+		sources [][]kernelLine
+		want    int
+	}{
+		{"none", [][]kernelLine{{{at: at(time.Hour), msg: "eth0: link up"}}}, 0},
+		{"other process", [][]kernelLine{{kill(time.Hour, "812", "java")}}, 0},
+		{"one kill", [][]kernelLine{{kill(time.Hour, "812", "mysqld")}}, 1},
+		{"older than lookback", [][]kernelLine{{kill(8*24*time.Hour, "812", "mysqld")}}, 0},
+		{
+			"same kill in journal and kmsg",
+			[][]kernelLine{
+				{kill(time.Hour, "812", "mysqld")},
+				{kill(time.Hour-2*time.Second, "812", "mysqld")},
+			},
+			1,
+		},
+		{
+			"same pid after a reboot",
+			[][]kernelLine{
+				{kill(3*24*time.Hour, "812", "mysqld"), kill(time.Hour, "812", "mysqld")},
+				{kill(time.Hour, "812", "mysqld")},
+			},
+			2,
+		},
+		{
+			"mysqld and mariadbd",
+			[][]kernelLine{{kill(time.Hour, "812", "mysqld"), kill(time.Hour, "812", "mariadbd")}},
+			2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := mergeOOMKills(now, tt.sources...)
+			if len(scan.kills) != tt.want {
+				t.Errorf("got %d kills %v, want %d", len(scan.kills), scan.kills, tt.want)
+			}
+			for i := 1; i < len(scan.kills); i++ {
+				if scan.kills[i].at.Before(scan.kills[i-1].at) {
+					t.Errorf("kills not sorted: %v", scan.kills)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeOOMKillsSince(t *testing.T) {
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	kmsg := []kernelLine{{at: now.Add(-2 * time.Hour), msg: "Linux version 6.1"}}
+	if got := mergeOOMKills(now, kmsg).since; !got.Equal(now.Add(-2 * time.Hour)) {
+		t.Errorf("since = %v, want the first kmsg line", got)
+	}
+	journal := []kernelLine{{at: now.Add(-30 * 24 * time.Hour), msg: "Linux version 6.1"}}
+	if got := mergeOOMKills(now, journal, kmsg).since; !got.Equal(now.Add(-oomLookback)) {
+		t.Errorf("since = %v, want clamped to the lookback", got)
+	}
+}
@@ -0,0 +1,426 @@
+package checks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// RunOSChecks inspects kernel and process settings that affect MySQL.
+func RunOSChecks(m *db.MySQL, h *host.Info, e *Env) []Check {
+	if _, skipped := h.HostChecksSkipped(); skipped {
+		return runAll(func() Check { return checkRemoteHost(h) })
+	}
+	return runAll(
+		func() Check { return checkTransparentHugepages(h) },
+		func() Check { return checkNUMA(m, h, e) },
+		func() Check { return checkIOScheduler(m, h) },
+		func() Check { return checkMountOptions(m, h) },
+		func() Check { return checkDirtyRatio(h) },
+		func() Check { return checkSwappiness(h) },
+		func() Check { return checkProcessLimits(m, h, e) },
+	)
+}
+
+func checkTransparentHugepages(h *host.Info) Check {
+	c := Check{
+		Name:        "Transparent Hugepages",
+		Threshold:   "never/madvise OK, always WARN",
+		Description: "Kernel transparent hugepage (THP) mode.",
+		Detail: "With THP set to \"always\" the kernel backs any large anonymous mapping " +
+			"with 2MB pages and compacts memory in the background to find them. For " +
+			"mysqld this causes latency spikes from compaction stalls and memory bloat, " +
+			"since allocators like jemalloc cannot return partial hugepages. Set it to " +
+			"\"never\" or \"madvise\", e.g. transparent_hugepage=never on the kernel " +
+			"command line.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	enabled, err := readSysfsChoice("/sys/kernel/mm/transparent_hugepage/enabled")
+	if err != nil {
+		return skip(c, "transparent hugepages not supported by this kernel")
+	}
+	c.Value = enabled
+	if defrag, err := readSysfsChoice("/sys/kernel/mm/transparent_hugepage/defrag"); err == nil {
+		c.Value += " (defrag " + defrag + ")"
+	}
+	if enabled == "always" {
+		c.Level = LevelWarn
+	} else {
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkNUMA(m *db.MySQL, h *host.Info, e *Env) Check {
+	c := Check{
+		Name:        "NUMA Memory Policy",
+		Threshold:   "single node, or interleaved with zone_reclaim_mode=0 OK, else WARN",
+		Description: "Whether mysqld spreads its memory over all NUMA nodes.",
+		Detail: "On multi-socket hosts each CPU has its own local memory. With the default " +
+			"policy a large buffer pool fills one node first; the kernel then swaps or " +
+			"reclaims cache on that node while others are free. innodb_numa_interleave=ON " +
+			"(or starting mysqld under numactl --interleave=all) spreads the buffer pool " +
+			"over all nodes. vm.zone_reclaim_mode should be 0 so the kernel uses remote " +
+			"memory instead of dropping local page cache.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	nodes, _ := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	if len(nodes) <= 1 {
+		c.Value = "single node"
+		c.Level = LevelOK
+		return c
+	}
+
+	interleave := strings.EqualFold(m.Vars["innodb_numa_interleave"], "ON")
+	if !interleave {
+		if pid, err := e.mysqldPid(); err == nil {
+			interleave = numaMapsInterleaved(pid)
+		}
+	}
+	zoneReclaim, err := readSysctl("vm/zone_reclaim_mode")
+	if err != nil {
+		zoneReclaim = "?"
+	}
+
+	policy := "default"
+	if interleave {
+		policy = "interleave"
+	}
+	c.Value = fmt.Sprintf("%d nodes, %s policy, zone_reclaim_mode=%s", len(nodes), policy, zoneReclaim)
+	if interleave && (zoneReclaim == "0" || zoneReclaim == "?") {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelWarn
+	}
+	return c
+}
+
+// numaMapsInterleaved reports whether the process's heap is mapped with an
+// interleave policy, as set by numactl --interleave.
+func numaMapsInterleaved(pid int) bool {
+	data, err := host.ReadFile(fmt.Sprintf("/proc/%d/numa_maps", pid))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.Contains(line, " heap") || strings.Contains(line, " anon=") {
+			fields := strings.Fields(line)
+			return len(fields) > 1 && strings.HasPrefix(fields[1], "interleave")
+		}
+	}
+	return false
+}
+
+func checkIOScheduler(m *db.MySQL, h *host.Info) Check {
+	c := Check{
+		Name:        "I/O Scheduler",
+		Threshold:   "none/noop/mq-deadline/deadline/kyber OK, cfq/bfq WARN",
+		Description: "Block I/O scheduler of the device holding the datadir.",
+		Detail: "InnoDB does its own I/O ordering and flushing. Fair-queueing schedulers " +
+			"(cfq, bfq) add latency by idling between requests and favour interactive " +
+			"workloads; SSD and NVMe devices, and virtual disks whose host does its own " +
+			"scheduling, perform best with none, while deadline variants suit spinning " +
+			"disks.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	dev, err := dataDevice(m)
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	queue, name, err := blockQueueDir(dev)
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	sched, err := readSysfsChoice(filepath.Join(queue, "scheduler"))
+	if err != nil {
+		return skip(c, fmt.Sprintf("%s has no I/O scheduler", name))
+	}
+	media := "ssd"
+	if rot, err := host.ReadFile(filepath.Join(queue, "rotational")); err == nil && strings.TrimSpace(string(rot)) == "1" {
+		media = "rotational"
+	}
+
+	c.Value = fmt.Sprintf("%s: %s (%s)", name, sched, media)
+	switch sched {
+	case "cfq", "bfq":
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+// blockQueueDir returns the sysfs queue directory and kernel name of the
+// block device "major:minor". Partitions have no queue of their own; their
+// parent disk's is used.
+func blockQueueDir(dev string) (string, string, error) {
+	sys, err := filepath.EvalSymlinks("/sys/dev/block/" + dev)
+	if err != nil {
+		return "", "", fmt.Errorf("block device %s not found in sysfs", dev)
+	}
+	name := filepath.Base(sys)
+	if _, err := os.Stat(filepath.Join(sys, "partition")); err == nil {
+		sys = filepath.Dir(sys)
+	}
+	return filepath.Join(sys, "queue"), name, nil
+}
+
+func checkMountOptions(m *db.MySQL, h *host.Info) Check {
+	c := Check{
+		Name:        "Datadir Mount Options",
+		Threshold:   "noatime/relatime with barriers OK, strictatime or nobarrier WARN",
+		Description: "Mount options of the filesystem holding the datadir.",
+		Detail: "Without noatime every read of a table file updates its access time, " +
+			"turning reads into metadata writes; relatime limits this to once a day, " +
+			"noatime avoids it. nobarrier (or barrier=0) disables write cache flushes, " +
+			"so a power loss can corrupt InnoDB even with fully durable settings unless " +
+			"the controller has a battery-backed cache.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	datadir := m.Vars["datadir"]
+	if datadir == "" {
+		return skip(c, "datadir not known")
+	}
+	mt, err := findMount(datadir)
+	if err != nil {
+		return skip(c, err.Error())
+	}
+
+	opts := make(map[string]bool)
+	for _, o := range strings.Split(mt.options+","+mt.superOptions, ",") {
+		opts[o] = true
+	}
+	atime := "strictatime"
+	switch {
+	case opts["noatime"]:
+		atime = "noatime"
+	case opts["relatime"]:
+		atime = "relatime"
+	}
+	noBarrier := opts["nobarrier"] || opts["barrier=0"]
+	barrier := "barriers on"
+	if noBarrier {
+		barrier = "barriers off"
+	}
+
+	c.Value = fmt.Sprintf("%s on %s: %s, %s", mt.fsType, mt.mountPoint, atime, barrier)
+	if atime == "strictatime" || noBarrier {
+		c.Level = LevelWarn
+	} else {
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkDirtyRatio(h *host.Info) Check {
+	c := Check{
+		Name:        "Dirty Page Limits",
+		Threshold:   "dirty_ratio <= 20 and dirty_background_ratio <= 10 OK, else WARN",
+		Description: "How much dirty page cache the kernel allows before forcing writeback.",
+		Detail: "vm.dirty_ratio is the share of memory that may hold unwritten file data " +
+			"before writing processes are blocked; vm.dirty_background_ratio is where " +
+			"background writeback starts. On hosts with a lot of memory, high values let " +
+			"gigabytes of binlog, redo and temporary file writes pile up and then stall " +
+			"mysqld while they are flushed. The *_bytes variants override the ratios " +
+			"when set.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	ratio, err := readSysctlInt("vm/dirty_ratio")
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	bg, err := readSysctlInt("vm/dirty_background_ratio")
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	ratioBytes, _ := readSysctlInt("vm/dirty_bytes")
+	bgBytes, _ := readSysctlInt("vm/dirty_background_bytes")
+
+	limit := fmt.Sprintf("dirty_ratio=%d", ratio)
+	if ratioBytes > 0 {
+		limit = "dirty_bytes=" + fmtBytes(float64(ratioBytes))
+	}
+	bgLimit := fmt.Sprintf("dirty_background_ratio=%d", bg)
+	if bgBytes > 0 {
+		bgLimit = "dirty_background_bytes=" + fmtBytes(float64(bgBytes))
+	}
+	c.Value = limit + ", " + bgLimit
+
+	if (ratioBytes == 0 && ratio > 20) || (bgBytes == 0 && bg > 10) {
+		c.Level = LevelWarn
+	} else {
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkSwappiness(h *host.Info) Check {
+	c := Check{
+		Name:        "Swappiness",
+		Threshold:   "<= 10 OK, > 10 WARN",
+		Description: "vm.swappiness, the kernel's preference for swapping over dropping page cache.",
+		Detail: "With the default of 60 the kernel readily swaps out idle parts of the " +
+			"buffer pool to keep page cache, which InnoDB does not need since it caches " +
+			"pages itself. Swapped-out buffer pool pages make later reads far slower than " +
+			"a disk read. Database hosts typically run with 1-10.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	v, err := readSysctlInt("vm/swappiness")
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	c.Value = strconv.FormatInt(v, 10)
+	if v <= 10 {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelWarn
+	}
+	return c
+}
+
+func checkProcessLimits(m *db.MySQL, h *host.Info, e *Env) Check {
+	c := Check{
+		Name:        "mysqld Process Limits",
+		Threshold:   "open files >= need and processes >= max_connections + 100 OK, else WARN; below max_connections CRIT",
+		Description: "Resource limits of the running mysqld compared to its configuration.",
+		Detail: "mysqld can only raise open_files_limit up to the hard limit it was " +
+			"started with, and each connection is a thread that counts against the " +
+			"\"Max processes\" limit of the mysql user. A file limit below max_connections " +
+			"plus twice table_open_cache leads to \"Too many open files\" errors; a " +
+			"process limit below max_connections makes new connections fail with " +
+			"\"Can't create a new thread\". Raise LimitNOFILE and LimitNPROC in the " +
+			"systemd unit.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	pid, err := e.mysqldPid()
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	limits, err := readProcLimits(pid)
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	files, hasFiles := limits["Max open files"]
+	procs, hasProcs := limits["Max processes"]
+	if !hasFiles || !hasProcs {
+		return skip(c, "limits not found in /proc/<pid>/limits")
+	}
+
+	maxConn := int64(varFloat(m, "max_connections"))
+	fileNeed := maxConn + 2*int64(varFloat(m, "table_open_cache")) + 10
+	if ofl := int64(varFloat(m, "open_files_limit")); ofl > fileNeed {
+		fileNeed = ofl
+	}
+
+	c.Value = fmt.Sprintf("open files %s (need %d), processes %s (max_connections %d)",
+		fmtLimit(files), fileNeed, fmtLimit(procs), maxConn)
+	switch {
+	case procs >= 0 && procs < maxConn, files >= 0 && files < maxConn:
+		c.Level = LevelCrit
+	case procs >= 0 && procs < maxConn+100, files >= 0 && files < fileNeed:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+// readProcLimits returns the soft limits from /proc/<pid>/limits, keyed by
+// name. "unlimited" is returned as -1.
+func readProcLimits(pid int) (map[string]int64, error) {
+	data, err := host.ReadFile(fmt.Sprintf("/proc/%d/limits", pid))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcLimits(string(data)), nil
+}
+
+// parseProcLimits returns the soft limits of a /proc/<pid>/limits file by
+// name, with -1 for unlimited.
+func parseProcLimits(data string) map[string]int64 {
+	limits := make(map[string]int64)
+	for _, line := range strings.Split(data, "\n") {
+		// Columns are aligned: the name takes the first 26 characters.
+		if len(line) < 26 || strings.HasPrefix(line, "Limit") {
+			continue
+		}
+		name := strings.TrimSpace(line[:26])
+		fields := strings.Fields(line[26:])
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "unlimited" {
+			limits[name] = -1
+		} else if v, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			limits[name] = v
+		}
+	}
+	return limits
+}
+
+func fmtLimit(v int64) string {
+	if v < 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// readSysfsChoice returns the selected entry of a sysfs file listing the
+// alternatives with the active one in brackets, e.g. "always [madvise] never".
+func readSysfsChoice(path string) (string, error) {
+	data, err := host.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSpace(string(data))
+	if start := strings.IndexByte(s, '['); start >= 0 {
+		if end := strings.IndexByte(s[start:], ']'); end > 0 {
+			return s[start+1 : start+end], nil
+		}
+	}
+	// Single-queue devices without alternatives print just the name.
+	return s, nil
+}
+
+// readSysctl reads a value below /proc/sys, e.g. "vm/swappiness".
+func readSysctl(name string) (string, error) {
+	data, err := host.ReadFile("/proc/sys/" + name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readSysctlInt(name string) (int64, error) {
+	s, err := readSysctl(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
@@ -0,0 +1,50 @@
+package checks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProcLimits(t *testing.T) {
+	const header = "Limit                     Soft Limit           Hard Limit           Units     \n"
+	tests := []struct {
+		name string
+		in   string
+		want map[string]int64
+	}{
+		{
+			"typical mysqld",
+			header +
+				"Max cpu time              unlimited            unlimited            seconds   \n" +
+				"Max open files            10000                10000                files     \n" +
+				"Max processes             63383                63383                processes \n" +
+				"Max locked memory         8388608              8388608              bytes     \n" +
+				"Max core file size        0                    unlimited            bytes     \n",
+			map[string]int64{
+				"Max cpu time":       -1,
+				"Max open files":     10000,
+				"Max processes":      63383,
+				"Max locked memory":  8388608,
+				"Max core file size": 0,
+			},
+		},
+		{
+			"soft limit below hard limit",
+			header + "Max open files            1024                 524288               files     \n",
+			map[string]int64{"Max open files": 1024},
+		},
+		{"header only", header, map[string]int64{}},
+		{
+			"short and malformed lines are skipped",
+			header + "Max open files\n" + "Max nice priority         x                    0                    \n",
+			map[string]int64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseProcLimits(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProcLimits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package checks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// serverComms are the process names of MySQL-compatible servers.
+var serverComms = map[string]bool{
+	"mysqld":   true,
+	"mariadbd": true,
+}
+
+// findMysqldPid returns the pid of the server m is connected to. With a
+// single mysqld/mariadbd process on the host that process is used; with
+// several, the one matching the server's pid_file, listening socket or
+// port, or --datadir is chosen. NewEnv calls it once per run.
+func findMysqldPid(m *db.MySQL) (int, error) {
+	candidates, err := serverProcesses()
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, fmt.Errorf("no mysqld or mariadbd process found")
+	}
+
+	if pid, ok := pidFromPidFile(m.Vars["pid_file"], m.Vars["datadir"]); ok && contains(candidates, pid) {
+		return pid, nil
+	}
+	if pid, ok := pidFromListener(m, candidates); ok {
+		return pid, nil
+	}
+	if pid, ok := pidFromDatadir(m.Vars["datadir"], candidates); ok {
+		return pid, nil
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	return 0, fmt.Errorf("%d server processes found, none matches pid_file, socket, port or datadir", len(candidates))
+}
+
+// serverProcesses lists the pids of all mysqld/mariadbd processes.
+func serverProcesses() ([]int, error) {
+	entries, err := readDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := host.ReadFile(filepath.Join("/proc", e.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		if serverComms[strings.TrimSpace(string(comm))] {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// pidFromPidFile reads the server's pid file. A relative pid_file is
+// resolved against datadir, as mysqld does.
+func pidFromPidFile(path, datadir string) (int, bool) {
+	if path == "" {
+		return 0, false
+	}
+	if !filepath.IsAbs(path) && datadir != "" {
+		path = filepath.Join(datadir, path)
+	}
+	data, err := host.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+// pidFromListener finds the candidate holding the server's listening unix
+// socket or TCP port. Reading other processes' fds requires running as the
+// same user as mysqld or as root.
+func pidFromListener(m *db.MySQL, candidates []int) (int, bool) {
+	inodes := make(map[uint64]bool)
+	if sock := m.Vars["socket"]; sock != "" {
+		for ino := range unixSocketInodes(sock) {
+			inodes[ino] = true
+		}
+	}
+	if port, err := strconv.Atoi(m.Vars["port"]); err == nil && port > 0 {
+		if socks, err := readTCPSockets("/proc/net"); err == nil {
+			for _, s := range socks {
+				if s.state == tcpListen && s.localPort == port {
+					inodes[s.inode] = true
+				}
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return 0, false
+	}
+
+	for _, pid := range candidates {
+		fdDir := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+		fds, err := readDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			ino, ok := strings.CutPrefix(link, "socket:[")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseUint(strings.TrimSuffix(ino, "]"), 10, 64)
+			if err == nil && inodes[n] {
+				return pid, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// pidFromDatadir matches --datadir on the candidates' command lines.
+func pidFromDatadir(datadir string, candidates []int) (int, bool) {
+	if datadir == "" {
+		return 0, false
+	}
+	want := filepath.Clean(datadir)
+	for _, pid := range candidates {
+		data, err := host.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+		if err != nil {
+			continue
+		}
+		for _, arg := range strings.Split(string(data), "\x00") {
+			if v, ok := strings.CutPrefix(arg, "--datadir="); ok && filepath.Clean(v) == want {
+				return pid, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func contains(pids []int, pid int) bool {
+	for _, p := range pids {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package checks
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Host data is read through these wrappers so that -debug shows every
+// /proc, /sys and statfs access together with the error, if any. Files
+// are read with host.ReadFile; statfs is in statfs_linux.go.
+
+func readDir(path string) ([]os.DirEntry, error) {
+	start := time.Now()
+	entries, err := os.ReadDir(path)
+	slog.Debug("read dir", "path", path, "entries", len(entries), "duration", time.Since(start), "err", err)
+	return entries, err
+}
@@ -0,0 +1,167 @@
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+func checkMysqldMemory(m *db.MySQL, h *host.Info, e *Env) Check {
+	c := Check{
+		Name:        "mysqld Memory (RSS)",
+		Threshold:   "RSS <= 1.5x buffer pool or overhead < 1GB OK, else WARN",
+		Description: "Resident memory of the mysqld process compared with innodb_buffer_pool_size.",
+		Detail: "The buffer pool is normally the bulk of mysqld's memory. Resident memory " +
+			"far above it points at per-connection buffers, large temporary tables, " +
+			"performance_schema instrumentation, memory fragmentation in the allocator " +
+			"or a leak. The peak (VmHWM) shows how close mysqld has come to its " +
+			"high-water mark since it started. Compare with the memory available to " +
+			"mysqld before raising innodb_buffer_pool_size.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	pid, err := e.mysqldPid()
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	status, err := readKBFields(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	rss, ok := status["VmRSS"]
+	if !ok {
+		return skip(c, "VmRSS not reported")
+	}
+
+	bp := varFloat(m, "innodb_buffer_pool_size")
+	c.Value = fmt.Sprintf("%s (peak %s)", fmtBytes(float64(rss)), fmtBytes(float64(status["VmHWM"])))
+	if bp == 0 {
+		c.Level = LevelOK
+		return c
+	}
+
+	ratio := float64(rss) / bp
+	c.Value = fmt.Sprintf("%s, %.2fx buffer pool (peak %s)", fmtBytes(float64(rss)), ratio, fmtBytes(float64(status["VmHWM"])))
+	if ratio <= 1.5 || float64(rss)-bp < 1<<30 {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelWarn
+	}
+	return c
+}
+
+func checkMysqldSwap(h *host.Info, e *Env) Check {
+	c := Check{
+		Name:        "mysqld Swapped Out",
+		Threshold:   "< 1% OK, 1-10% WARN, > 10% CRIT",
+		Description: "Share of the mysqld process's memory that has been swapped out.",
+		Detail: "When pages of the buffer pool or other mysqld memory are swapped out, " +
+			"accessing them turns a memory read into a disk read, and the buffer pool's " +
+			"own LRU decisions are defeated. Any significant swap usage by mysqld makes " +
+			"query latency unpredictable. Reduce innodb_buffer_pool_size or other memory " +
+			"consumers, lower vm.swappiness, or add RAM.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	pid, err := e.mysqldPid()
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	status, err := readKBFields(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	rss := status["VmRSS"]
+	swap := status["VmSwap"]
+	// smaps_rollup also counts swapped shared memory, which VmSwap misses.
+	if rollup, err := readKBFields(fmt.Sprintf("/proc/%d/smaps_rollup", pid)); err == nil && rollup["Swap"] > swap {
+		swap = rollup["Swap"]
+	}
+	v, ok := pct(float64(swap), float64(rss+swap))
+	if !ok {
+		return skip(c, "no memory reported for mysqld")
+	}
+
+	c.Value = fmt.Sprintf("%s (%s)", fmtBytes(float64(swap)), fmtPct(v))
+	switch {
+	case v < 1:
+		c.Level = LevelOK
+	case v <= 10:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkHostSwap(h *host.Info) Check {
+	c := Check{
+		Name:        "Host Swap Usage",
+		Threshold:   "< 25% OK, 25-75% WARN, > 75% CRIT",
+		Description: "Swap space in use on the host.",
+		Detail: "Swap in use means the host has been short of memory at some point. " +
+			"Heavy swap usage on a database host usually precedes OOM kills. How " +
+			"eagerly the kernel swaps is reported by the Swappiness check in the " +
+			"Operating System category.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	info, err := readKBFields("/proc/meminfo")
+	if err != nil {
+		return skip(c, err.Error())
+	}
+	total := info["SwapTotal"]
+	if total == 0 {
+		c.Value = "no swap"
+		c.Level = LevelOK
+		return c
+	}
+	used := total - info["SwapFree"]
+	v := float64(used) * 100.0 / float64(total)
+
+	c.Value = fmt.Sprintf("%s of %s", fmtPct(v), fmtBytes(float64(total)))
+	switch {
+	case v < 25:
+		c.Level = LevelOK
+	case v <= 75:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+// readKBFields parses "Key:   1234 kB" lines as found in /proc/meminfo,
+// /proc/<pid>/status and smaps_rollup. Values are returned in bytes.
+func readKBFields(path string) (map[string]uint64, error) {
+	data, err := host.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		parts := strings.Fields(rest)
+		if len(parts) != 2 || parts[1] != "kB" {
+			continue
+		}
+		v, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		fields[key] = v * 1024
+	}
+	return fields, nil
+}
@@ -0,0 +1,163 @@
+package checks
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// tcpState values as used in /proc/net/tcp (include/net/tcp_states.h).
+const (
+	tcpEstablished = 0x01
+	tcpSynRecv     = 0x03
+	tcpCloseWait   = 0x08
+	tcpListen      = 0x0A
+)
+
+var tcpStateNames = map[int]string{
+	0x01: "ESTABLISHED",
+	0x02: "SYN_SENT",
+	0x03: "SYN_RECV",
+	0x04: "FIN_WAIT1",
+	0x05: "FIN_WAIT2",
+	0x06: "TIME_WAIT",
+	0x07: "CLOSE",
+	0x08: "CLOSE_WAIT",
+	0x09: "LAST_ACK",
+	0x0A: "LISTEN",
+	0x0B: "CLOSING",
+}
+
+// tcpSocket is one line of /proc/net/tcp or /proc/net/tcp6.
+type tcpSocket struct {
+	localIP    net.IP
+	localPort  int
+	remoteIP   net.IP
+	remotePort int
+	state      int
+	txQueue    uint64
+	rxQueue    uint64 // for listeners: connections waiting in the accept queue
+	inode      uint64
+}
+
+// readTCPSockets parses tcp and tcp6 in netDir, which is /proc/net for our
+// own network namespace or /proc/<pid>/net for another process's. Either
+// file may be missing (e.g. IPv6 disabled).
+func readTCPSockets(netDir string) ([]tcpSocket, error) {
+	var socks []tcpSocket
+	var firstErr error
+	read := 0
+	for _, name := range []string{"tcp", "tcp6"} {
+		data, err := host.ReadFile(filepath.Join(netDir, name))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		read++
+		lines := strings.Split(string(data), "\n")
+		for _, line := range lines[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 {
+				continue
+			}
+			var s tcpSocket
+			var ok bool
+			if s.localIP, s.localPort, ok = parseHexAddr(fields[1]); !ok {
+				continue
+			}
+			if s.remoteIP, s.remotePort, ok = parseHexAddr(fields[2]); !ok {
+				continue
+			}
+			st, _ := strconv.ParseUint(fields[3], 16, 8)
+			s.state = int(st)
+			if tx, rx, found := strings.Cut(fields[4], ":"); found {
+				s.txQueue, _ = strconv.ParseUint(tx, 16, 64)
+				s.rxQueue, _ = strconv.ParseUint(rx, 16, 64)
+			}
+			s.inode, _ = strconv.ParseUint(fields[9], 10, 64)
+			socks = append(socks, s)
+		}
+	}
+	if read == 0 {
+		return nil, firstErr
+	}
+	return socks, nil
+}
+
+// parseHexAddr decodes "0100007F:0CEA" style addresses. The kernel prints
+// the address as a sequence of 32-bit words in host byte order, so each
+// word is stored back in native byte order to get the network order.
+func parseHexAddr(s string) (net.IP, int, bool) {
+	addr, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, false
+	}
+	raw, err := hex.DecodeString(addr)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return nil, 0, false
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.NativeEndian.PutUint32(ip[i:], binary.BigEndian.Uint32(raw[i:]))
+	}
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return nil, 0, false
+	}
+	return ip, int(p), true
+}
+
+// unixSocketInodes returns the inodes of listening unix sockets bound to
+// path, from /proc/net/unix.
+func unixSocketInodes(path string) map[uint64]bool {
+	inodes := make(map[uint64]bool)
+	data, err := host.ReadFile("/proc/net/unix")
+	if err != nil {
+		return inodes
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 || fields[7] != path {
+			continue
+		}
+		if ino, err := strconv.ParseUint(fields[6], 10, 64); err == nil {
+			inodes[ino] = true
+		}
+	}
+	return inodes
+}
+
+// readNetCounters parses the protocol counters in netDir/snmp and
+// netDir/netstat into "Proto.Name" keys, e.g. "TcpExt.ListenOverflows".
+// Both files consist of header/value line pairs.
+func readNetCounters(netDir string) (map[string]int64, error) {
+	counters := make(map[string]int64)
+	for _, name := range []string{"snmp", "netstat"} {
+		data, err := host.ReadFile(filepath.Join(netDir, name))
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(string(data), "\n")
+		for i := 0; i+1 < len(lines); i += 2 {
+			keys := strings.Fields(lines[i])
+			vals := strings.Fields(lines[i+1])
+			if len(keys) == 0 || len(keys) != len(vals) || keys[0] != vals[0] {
+				return nil, fmt.Errorf("unexpected format in %s", filepath.Join(netDir, name))
+			}
+			proto := strings.TrimSuffix(keys[0], ":")
+			for j := 1; j < len(keys); j++ {
+				v, _ := strconv.ParseInt(vals[j], 10, 64)
+				counters[proto+"."+keys[j]] = v
+			}
+		}
+	}
+	return counters, nil
+}
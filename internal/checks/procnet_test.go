@@ -0,0 +1,212 @@
+package checks
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// procHexAddr formats ip and port the way the kernel prints them in
+// /proc/net/tcp{,6}: each 32-bit word of the address in host byte order.
+func procHexAddr(ip string, port int) string {
+	raw := net.ParseIP(ip)
+	if v4 := raw.To4(); v4 != nil {
+		raw = v4
+	}
+	var b strings.Builder
+	for i := 0; i < len(raw); i += 4 {
+		fmt.Fprintf(&b, "%08X", binary.NativeEndian.Uint32(raw[i:]))
+	}
+	fmt.Fprintf(&b, ":%04X", port)
+	return b.String()
+}
+
+func TestParseHexAddr(t *testing.T) {
+	tests := []struct {
+		ip   string
+		port int
+	}{
+		{"127.0.0.1", 3306},
+		{"10.1.2.3", 54321},
+		{"0.0.0.0", 0},
+		{"::", 3306},
+		{"::1", 33060},
+		{"2001:db8::17", 443},
+		{"::ffff:192.168.1.10", 3306},
+	}
+	for _, tt := range tests {
+		in := procHexAddr(tt.ip, tt.port)
+		ip, port, ok := parseHexAddr(in)
+		if !ok || !ip.Equal(net.ParseIP(tt.ip)) || port != tt.port {
+			t.Errorf("parseHexAddr(%q) = %v, %d, %v, want %s, %d", in, ip, port, ok, tt.ip, tt.port)
+		}
+	}
+}
+
+func TestParseHexAddrLittleEndian(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("literal addresses below are as printed by a little-endian kernel")
+	}
+	tests := []struct {
+		in   string
+		ip   string
+		port int
+	}{
+		{"0100007F:0CEA", "127.0.0.1", 3306},
+		{"00000000000000000000000001000000:0CEA", "::1", 3306},
+		{"0000000000000000FFFF00000A01A8C0:0CEA", "::ffff:192.168.1.10", 3306},
+	}
+	for _, tt := range tests {
+		ip, port, ok := parseHexAddr(tt.in)
+		if !ok || !ip.Equal(net.ParseIP(tt.ip)) || port != tt.port {
+			t.Errorf("parseHexAddr(%q) = %v, %d, %v, want %s, %d", tt.in, ip, port, ok, tt.ip, tt.port)
+		}
+	}
+}
+
+func TestParseHexAddrInvalid(t *testing.T) {
+	for _, in := range []string{"", "0100007F", "0100007:0CEA", "XX00007F:0CEA", "0100007F:GGGG", "0100007F:10000"} {
+		if _, _, ok := parseHexAddr(in); ok {
+			t.Errorf("parseHexAddr(%q) succeeded, want failure", in)
+		}
+	}
+}
+
+func writeProcNet(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadTCPSockets(t *testing.T) {
+	const header = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	line := func(sl int, local, remote string, state int, queues string, inode int) string {
+		return fmt.Sprintf("%4d: %s %s %02X %s 00:00000000 00000000   999        0 %d 1 0000000000000000 100 0 0 10 0\n",
+			sl, local, remote, state, queues, inode)
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []tcpSocket
+	}{
+		{
+			"IPv4 and IPv6",
+			map[string]string{
+				"tcp": header +
+					line(0, procHexAddr("0.0.0.0", 3306), procHexAddr("0.0.0.0", 0), tcpListen, "00000000:00000002", 1001) +
+					line(1, procHexAddr("10.0.0.5", 3306), procHexAddr("10.0.0.9", 51000), tcpEstablished, "0000001A:00000000", 1002),
+				"tcp6": header +
+					line(0, procHexAddr("::", 33060), procHexAddr("::", 0), tcpListen, "00000000:00000000", 1003),
+			},
+			[]tcpSocket{
+				{net.ParseIP("0.0.0.0").To4(), 3306, net.ParseIP("0.0.0.0").To4(), 0, tcpListen, 0, 2, 1001},
+				{net.ParseIP("10.0.0.5").To4(), 3306, net.ParseIP("10.0.0.9").To4(), 51000, tcpEstablished, 0x1A, 0, 1002},
+				{net.ParseIP("::"), 33060, net.ParseIP("::"), 0, tcpListen, 0, 0, 1003},
+			},
+		},
+		{
+			"IPv6 disabled",
+			map[string]string{
+				"tcp": header +
+					line(0, procHexAddr("127.0.0.1", 3306), procHexAddr("127.0.0.1", 40000), tcpCloseWait, "00000000:00000000", 7),
+			},
+			[]tcpSocket{
+				{net.ParseIP("127.0.0.1").To4(), 3306, net.ParseIP("127.0.0.1").To4(), 40000, tcpCloseWait, 0, 0, 7},
+			},
+		},
+		{
+			"short and malformed lines are skipped",
+			map[string]string{
+				"tcp": header + "   0: 0100007F:0CEA\n" +
+					line(1, "zz", procHexAddr("0.0.0.0", 0), tcpListen, "00000000:00000000", 1),
+			},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readTCPSockets(writeProcNet(t, tt.files))
+			if err != nil {
+				t.Fatalf("readTCPSockets: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readTCPSockets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTCPSocketsMissing(t *testing.T) {
+	if _, err := readTCPSockets(t.TempDir()); err == nil {
+		t.Error("readTCPSockets without tcp and tcp6 succeeded, want error")
+	}
+}
+
+func TestReadNetCounters(t *testing.T) {
+	snmp := "Ip: Forwarding DefaultTTL\nIp: 1 64\n" +
+		"Tcp: RtoAlgorithm ActiveOpens RetransSegs OutSegs\nTcp: 1 120 7 9000\n"
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    map[string]int64
+		wantErr bool
+	}{
+		{
+			"snmp and netstat",
+			map[string]string{
+				"snmp":    snmp,
+				"netstat": "TcpExt: SyncookiesSent ListenOverflows ListenDrops\nTcpExt: 0 12 15\n",
+			},
+			map[string]int64{
+				"Ip.Forwarding":          1,
+				"Ip.DefaultTTL":          64,
+				"Tcp.RtoAlgorithm":       1,
+				"Tcp.ActiveOpens":        120,
+				"Tcp.RetransSegs":        7,
+				"Tcp.OutSegs":            9000,
+				"TcpExt.SyncookiesSent":  0,
+				"TcpExt.ListenOverflows": 12,
+				"TcpExt.ListenDrops":     15,
+			},
+			false,
+		},
+		{
+			"mismatched header and values",
+			map[string]string{
+				"snmp":    snmp,
+				"netstat": "TcpExt: ListenOverflows ListenDrops\nTcpExt: 12\n",
+			},
+			nil, true,
+		},
+		{
+			"lines out of pairs",
+			map[string]string{
+				"snmp":    snmp,
+				"netstat": "TcpExt: ListenOverflows\nIpExt: 3\n",
+			},
+			nil, true,
+		},
+		{"netstat missing", map[string]string{"snmp": snmp}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readNetCounters(writeProcNet(t, tt.files))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readNetCounters() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readNetCounters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package checks
+
+import (
+	"fmt"
+
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// pressureSpec describes the thresholds for one PSI resource. Levels are
+// taken from the 60 second average; the 10 second one reacts to short
+// bursts and is shown for context.
+type pressureSpec struct {
+	resource  string
+	name      string
+	warn      float64 // "some" avg60 at which to WARN
+	crit      float64 // "some" avg60 at which to go CRIT
+	fullCrit  float64 // "full" avg60 at which to go CRIT; 0 ignores "full"
+	threshold string
+	desc      string
+	detail    string
+}
+
+var pressureSpecs = []pressureSpec{
+	{
+		resource:  "cpu",
+		name:      "CPU Pressure",
+		warn:      10,
+		crit:      25,
+		threshold: "some avg60 < 10% OK, 10-25% WARN, > 25% CRIT",
+		desc:      "Share of time runnable tasks waited for a CPU (PSI).",
+		detail: "Pressure stall information measures time lost waiting rather than time " +
+			"used: 100% CPU utilization with no pressure is a busy but healthy server, " +
+			"while pressure means queries are queueing for a core. It also captures " +
+			"throttling by a cgroup CPU quota, which utilization percentages hide.",
+	},
+	{
+		resource:  "memory",
+		name:      "Memory Pressure",
+		warn:      5,
+		crit:      20,
+		fullCrit:  5,
+		threshold: "some avg60 < 5% OK, 5-20% WARN, > 20% or full avg60 > 5% CRIT",
+		desc:      "Share of time tasks stalled on memory reclaim or swap-in (PSI).",
+		detail: "Memory pressure is time spent waiting for the kernel to free memory: " +
+			"direct reclaim, refaulting evicted page cache, and swapping in. It rises " +
+			"before the OOM killer acts, unlike used-memory percentages which stay high " +
+			"on any healthy host because of page cache. \"full\" means all tasks were " +
+			"stalled at once and the workload made no progress.",
+	},
+	{
+		resource:  "io",
+		name:      "I/O Pressure",
+		warn:      10,
+		crit:      30,
+		fullCrit:  10,
+		threshold: "some avg60 < 10% OK, 10-30% WARN, > 30% or full avg60 > 10% CRIT",
+		desc:      "Share of time tasks waited for block I/O (PSI).",
+		detail: "I/O pressure is time tasks spent blocked on disk reads and writes. For " +
+			"MySQL this is buffer pool misses, redo log and binlog fsyncs and temporary " +
+			"tables. Unlike iowait it is not hidden when other tasks keep the CPU busy, " +
+			"and in a cgroup it also includes throttling by I/O limits.",
+	},
+}
+
+func checkPressure(h *host.Info, e *Env, spec pressureSpec) Check {
+	c := Check{
+		Name:        spec.name,
+		Threshold:   spec.threshold,
+		Description: spec.desc,
+		Detail: spec.detail + " The host-wide value is from /proc/pressure; with cgroup v2 " +
+			"the value for mysqld's own cgroup is shown too and the higher of the two is " +
+			"used.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	sys, err := host.ReadPressure(spec.resource)
+	if err != nil {
+		return skip(c, "PSI not available (kernel without CONFIG_PSI or psi=0)")
+	}
+	c.Value = fmtPressure(sys, spec.fullCrit > 0)
+	some, full := sys.Some.Avg60, sys.Full.Avg60
+
+	if pid, err := e.mysqldPid(); err == nil {
+		if cg, err := host.ReadCgroup(pid); err == nil && cg.Dir != "" && cg.Path != "/" {
+			if p, err := cg.Pressure(spec.resource); err == nil {
+				c.Value += "; cgroup " + fmtPressure(p, spec.fullCrit > 0)
+				some = max(some, p.Some.Avg60)
+				full = max(full, p.Full.Avg60)
+			}
+		}
+	}
+
+	switch {
+	case some > spec.crit, spec.fullCrit > 0 && full > spec.fullCrit:
+		c.Level = LevelCrit
+	case some >= spec.warn:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func fmtPressure(p *host.Pressure, withFull bool) string {
+	s := fmt.Sprintf("some avg10 %.1f%% avg60 %.1f%%", p.Some.Avg10, p.Some.Avg60)
+	if withFull && p.HasFull {
+		s += fmt.Sprintf(", full avg10 %.1f%% avg60 %.1f%%", p.Full.Avg10, p.Full.Avg60)
+	}
+	return s
+}
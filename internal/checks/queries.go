@@ -5,17 +5,18 @@ import (
 	"strconv"
 
 	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
 )
 
-func RunQueryChecks(m *db.MySQL) []Check {
-	var results []Check
-	results = append(results, checkSortMergePassRatio(m))
-	results = append(results, checkSortBufferMemoryRisk(m))
-	results = append(results, checkTempDiskData(m))
-	results = append(results, checkFlushingLogs(m))
-	results = append(results, checkQCacheFragmentation(m))
-	results = append(results, checkQueryTruncation(m))
-	return results
+func RunQueryChecks(m *db.MySQL, h *host.Info, e *Env) []Check {
+	return runAll(
+		func() Check { return checkSortMergePassRatio(m) },
+		func() Check { return checkSortBufferMemoryRisk(m, h, e) },
+		func() Check { return checkTempDiskData(m) },
+		func() Check { return checkFlushingLogs(m) },
+		func() Check { return checkQCacheFragmentation(m) },
+		func() Check { return checkQueryTruncation(m) },
+	)
 }
 
 func checkSortMergePassRatio(m *db.MySQL) Check {
@@ -50,16 +51,17 @@ func checkSortMergePassRatio(m *db.MySQL) Check {
 	return c
 }
 
-func checkSortBufferMemoryRisk(m *db.MySQL) Check {
+func checkSortBufferMemoryRisk(m *db.MySQL, h *host.Info, e *Env) Check {
 	c := Check{
 		Name:      "Sort Buffer Memory Risk",
 		Threshold: "< 25% of RAM = OK, >= 25% WARN",
 		Description: "Worst-case memory if all connections run a sort simultaneously.",
 		Detail: "sort_buffer_size is allocated per thread per sort operation, so at peak " +
 			"concurrency the total usage is sort_buffer_size × max_connections. If that " +
-			"theoretical maximum exceeds 25% of total RAM, a sudden burst of parallel " +
-			"sort-heavy queries can cause memory exhaustion or swapping. Consider reducing " +
-			"sort_buffer_size or max_connections if the risk is high.",
+			"theoretical maximum exceeds 25% of total RAM (or of mysqld's cgroup memory " +
+			"limit), a sudden burst of parallel sort-heavy queries can cause memory " +
+			"exhaustion or swapping. Consider reducing sort_buffer_size or " +
+			"max_connections if the risk is high.",
 	}
 
 	sortBuf := varFloat(m, "sort_buffer_size")
@@ -70,7 +72,12 @@ func checkSortBufferMemoryRisk(m *db.MySQL) Check {
 		return c
 	}
 
-	totalRAM, _, err := readMeminfo()
+	// RAM is read from the local /proc, which only describes the server
+	// when it runs on this host.
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	totalRAM, err := effectiveMemory(e)
 	if err != nil || totalRAM == 0 {
 		c.Value = "N/A"
 		c.Level = LevelSkip
@@ -214,13 +221,20 @@ func checkQueryTruncation(m *db.MySQL) Check {
 			"performance_schema_max_digest_length to capture complete query text.",
 	}
 
+	if reason, missing := missingPrivilege(m, db.ReqPerformanceSchema); missing {
+		return skip(c, reason)
+	}
+	// Scanning every thread's statement history takes performance_schema
+	// locks; leave it out while the server is busy.
+	if reason, busy := m.Overloaded(); busy {
+		return skip(c, reason)
+	}
+
 	val, err := m.QueryScalar(
 		"SELECT COUNT(*) FROM performance_schema.events_statements_history WHERE SQL_TEXT LIKE '%...'",
 	)
 	if err != nil {
-		c.Value = "N/A"
-		c.Level = LevelSkip
-		return c
+		return skip(c, fmt.Sprintf("query failed: %v", err))
 	}
 
 	count, _ := strconv.Atoi(val)
@@ -0,0 +1,315 @@
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+)
+
+// RunReplicationChecks reports the state of each replication channel of a
+// replica, the replicas connected to a source, semi-synchronous replication
+// and Group Replication membership.
+func RunReplicationChecks(m *db.MySQL, e *Env) []Check {
+	results := runMany(func() []Check {
+		results := checkReplicaChannels(m, e.replicas, e.replicaErr)
+		return append(results, checkConnectedReplicas(m))
+	})
+	results = append(results, semiSyncChecks(m)...)
+	return append(results, groupReplicationChecks(m)...)
+}
+
+// replicaStatus returns one row per asynchronous replication channel.
+// MySQL 8.0.22 renamed SHOW SLAVE STATUS and its columns; MariaDB lists all
+// named connections with SHOW ALL SLAVES STATUS. The group_replication_*
+// channels of a Group Replication member are left out: their threads are
+// managed by the plugin and are reported by the Group checks. NewEnv reads
+// it once per run for the Replication and Durability categories.
+func replicaStatus(m *db.MySQL) ([]map[string]string, error) {
+	var rows []map[string]string
+	var err error
+	if m.IsMariaDB() || m.DryRun() {
+		rows, err = m.QueryRows("SHOW ALL SLAVES STATUS")
+	}
+	if !m.IsMariaDB() {
+		rows, err = m.QueryRows("SHOW REPLICA STATUS")
+		if err != nil {
+			rows, err = m.QueryRows("SHOW SLAVE STATUS")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	channels := rows[:0]
+	for _, row := range rows {
+		if !strings.HasPrefix(channelName(row), "group_replication_") {
+			channels = append(channels, row)
+		}
+	}
+	return channels, nil
+}
+
+// replField returns the first of the given column names present in row, so
+// that the 8.0.22+ names can be listed with their older equivalents.
+func replField(row map[string]string, names ...string) (string, bool) {
+	for _, n := range names {
+		if v, ok := row[n]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func channelName(row map[string]string) string {
+	name, _ := replField(row, "Channel_Name", "Channel_name", "Connection_name")
+	return name
+}
+
+func checkReplicaChannels(m *db.MySQL, channels []map[string]string, err error) []Check {
+	c := Check{
+		Name:        "Replica Status",
+		Threshold:   "N/A",
+		Description: "Whether this server replicates from a source.",
+		Detail: "Read from SHOW REPLICA STATUS (SHOW SLAVE STATUS before MySQL 8.0.22, " +
+			"SHOW ALL SLAVES STATUS on MariaDB), which returns one row per replication " +
+			"channel. The remaining replication checks are reported per channel.",
+	}
+
+	if reason, missing := missingPrivilege(m, db.ReqReplicationClient); missing {
+		return []Check{skip(c, reason)}
+	}
+	if err != nil {
+		return []Check{skip(c, fmt.Sprintf("query failed: %v", err))}
+	}
+	if len(channels) == 0 {
+		c.Value = "not a replica"
+		c.Level = LevelOK
+		return []Check{c}
+	}
+
+	var results []Check
+	for _, row := range channels {
+		suffix := ""
+		if name := channelName(row); name != "" {
+			suffix = " (" + name + ")"
+		}
+		for _, check := range []func(map[string]string) Check{
+			checkReplicaThreads,
+			checkReplicaLag,
+			checkRelayLogSpace(m),
+			checkGTIDGap(m),
+		} {
+			rc := check(row)
+			rc.Name += suffix
+			results = append(results, rc)
+		}
+	}
+	return results
+}
+
+func checkReplicaThreads(row map[string]string) Check {
+	c := Check{
+		Name:        "Replication Threads",
+		Threshold:   "both Yes OK, IO Connecting WARN, either No CRIT",
+		Description: "State of the replication I/O (receiver) and SQL (applier) threads.",
+		Detail: "The I/O thread fetches the source's binary log into the relay log; the " +
+			"SQL thread applies it. A stopped SQL thread usually means a statement " +
+			"failed to apply (see the error) and the replica is falling further behind " +
+			"with every transaction on the source. An I/O thread stuck in Connecting " +
+			"cannot reach or authenticate to the source.",
+	}
+
+	source, _ := replField(row, "Source_Host", "Master_Host")
+	io, _ := replField(row, "Replica_IO_Running", "Slave_IO_Running")
+	sqlRunning, _ := replField(row, "Replica_SQL_Running", "Slave_SQL_Running")
+
+	c.Value = fmt.Sprintf("IO %s, SQL %s (source %s)", io, sqlRunning, source)
+	if errno := row["Last_IO_Errno"]; errno != "" && errno != "0" {
+		c.Value += fmt.Sprintf("; IO error %s: %s", errno, row["Last_IO_Error"])
+	}
+	if errno := row["Last_SQL_Errno"]; errno != "" && errno != "0" {
+		c.Value += fmt.Sprintf("; SQL error %s: %s", errno, row["Last_SQL_Error"])
+	}
+	switch {
+	case io == "No" || sqlRunning == "No":
+		c.Level = LevelCrit
+	case io != "Yes" || sqlRunning != "Yes":
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkReplicaLag(row map[string]string) Check {
+	c := Check{
+		Name:        "Replication Lag",
+		Threshold:   "< 60s OK, 60-300s WARN, > 300s CRIT",
+		Description: "Seconds_Behind_Source: how far the applied events lag behind the source.",
+		Detail: "The difference between the replica's clock and the timestamp of the event " +
+			"being applied. Reads from a lagging replica return stale data, and a " +
+			"failover to it loses or delays the missing transactions. It is NULL when " +
+			"a replication thread is stopped, and only measures the applier: events " +
+			"the I/O thread has not fetched yet are not counted.",
+	}
+
+	lag, _ := replField(row, "Seconds_Behind_Source", "Seconds_Behind_Master")
+	if lag == "" || strings.EqualFold(lag, "NULL") {
+		return skip(c, "replication threads are not running")
+	}
+	secs, err := strconv.ParseFloat(lag, 64)
+	if err != nil {
+		return skip(c, "invalid Seconds_Behind_Source "+lag)
+	}
+
+	c.Value = fmt.Sprintf("%.0fs", secs)
+	switch {
+	case secs < 60:
+		c.Level = LevelOK
+	case secs <= 300:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkRelayLogSpace(m *db.MySQL) func(map[string]string) Check {
+	return func(row map[string]string) Check {
+		c := Check{
+			Name:        "Relay Log Space",
+			Threshold:   "< 90% of relay_log_space_limit, or < 10GB when unlimited, OK; else WARN",
+			Description: "Size of the relay logs waiting to be applied.",
+			Detail: "Relay logs grow when the SQL thread applies events slower than the I/O " +
+				"thread fetches them. Large relay logs use disk space in the datadir and " +
+				"must all be applied before a promoted replica is consistent. When " +
+				"relay_log_space_limit is reached the I/O thread pauses, so lag builds up " +
+				"on the source side instead.",
+		}
+
+		space, err := strconv.ParseFloat(row["Relay_Log_Space"], 64)
+		if err != nil {
+			return skip(c, "Relay_Log_Space not available")
+		}
+		limit := varFloat(m, "relay_log_space_limit")
+
+		c.Value = fmtBytes(space)
+		if limit > 0 {
+			v, _ := pct(space, limit)
+			c.Value += fmt.Sprintf(" of %s limit", fmtBytes(limit))
+			if v >= 90 {
+				c.Level = LevelWarn
+			} else {
+				c.Level = LevelOK
+			}
+			return c
+		}
+		if space >= 10<<30 {
+			c.Level = LevelWarn
+		} else {
+			c.Level = LevelOK
+		}
+		return c
+	}
+}
+
+func checkGTIDGap(m *db.MySQL) func(map[string]string) Check {
+	return func(row map[string]string) Check {
+		c := Check{
+			Name:        "GTID Apply Gap",
+			Threshold:   "< 1000 pending and no holes OK, holes or <= 100000 pending WARN, more CRIT",
+			Description: "Transactions retrieved from the source but not yet executed, and holes in the executed GTID set.",
+			Detail: "Retrieved_Gtid_Set minus Executed_Gtid_Set counts the transactions " +
+				"waiting in the relay log, a lag measure that does not depend on clocks. " +
+				"Holes in the executed set (e.g. uuid:1-100:102-200) mean transactions " +
+				"were skipped or applied out of order by a multi-threaded applier; a " +
+				"persistent hole is a transaction this replica will never have.",
+		}
+
+		if m.IsMariaDB() {
+			return skip(c, "MariaDB GTIDs use domain-server-sequence positions")
+		}
+		if !strings.EqualFold(m.Vars["gtid_mode"], "ON") {
+			return skip(c, "gtid_mode is not ON")
+		}
+		retrieved, err := parseGTIDSet(row["Retrieved_Gtid_Set"])
+		if err != nil {
+			return skip(c, err.Error())
+		}
+		executed, err := parseGTIDSet(row["Executed_Gtid_Set"])
+		if err != nil {
+			return skip(c, err.Error())
+		}
+
+		pending := retrieved.minus(executed)
+		holes := executed.holes()
+		c.Value = fmt.Sprintf("%d retrieved not executed, %d holes in executed set", pending, holes)
+		switch {
+		case pending > 100000:
+			c.Level = LevelCrit
+		case pending >= 1000 || holes > 0:
+			c.Level = LevelWarn
+		default:
+			c.Level = LevelOK
+		}
+		return c
+	}
+}
+
+func checkConnectedReplicas(m *db.MySQL) Check {
+	c := Check{
+		Name:        "Connected Replicas",
+		Threshold:   "N/A",
+		Description: "Replicas currently reading this server's binary log.",
+		Detail: "SHOW REPLICAS (SHOW SLAVE HOSTS before MySQL 8.0.22) lists replicas that " +
+			"registered with report_host. It needs REPLICATION SLAVE, which also allows " +
+			"reading the whole binary log, so the grants subcommand does not include it; " +
+			"the binlog dump threads in the process list (PROCESS) are counted instead. " +
+			"A source whose replicas disappeared is no longer protected by them.",
+	}
+
+	rows, err := m.QueryRows("SHOW REPLICAS")
+	if err != nil {
+		rows, err = m.QueryRows("SHOW SLAVE HOSTS")
+	}
+	if err == nil && len(rows) > 0 {
+		hosts := make([]string, len(rows))
+		for i, r := range rows {
+			h, _ := replField(r, "Host")
+			p, _ := replField(r, "Port")
+			id, _ := replField(r, "Server_Id", "Server_id")
+			if h == "" {
+				h = "?"
+			}
+			hosts[i] = fmt.Sprintf("%s:%s (server_id %s)", h, p, id)
+		}
+		c.Value = fmt.Sprintf("%d: %s", len(rows), strings.Join(hosts, ", "))
+		c.Level = LevelOK
+		return c
+	}
+
+	if reason, missing := missingPrivilege(m, db.ReqProcess); missing {
+		return skip(c, reason)
+	}
+	dumps, err := m.QueryRows("SELECT HOST FROM information_schema.PROCESSLIST WHERE COMMAND LIKE 'Binlog Dump%'")
+	if err != nil {
+		return skip(c, fmt.Sprintf("query failed: %v", err))
+	}
+	if len(dumps) == 0 {
+		if !strings.EqualFold(m.Vars["log_bin"], "ON") {
+			c.Value = "none (binary log disabled)"
+		} else {
+			c.Value = "none"
+		}
+		c.Level = LevelOK
+		return c
+	}
+	hosts := make([]string, len(dumps))
+	for i, r := range dumps {
+		hosts[i] = r["HOST"]
+	}
+	c.Value = fmt.Sprintf("%d: %s", len(dumps), strings.Join(hosts, ", "))
+	c.Level = LevelOK
+	return c
+}
@@ -0,0 +1,144 @@
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// snapshot holds the counters read at one end of the sample window.
+type snapshot struct {
+	at time.Time
+
+	procUser, procSystem int64 // mysqld utime/stime in clock ticks
+	procErr              error
+
+	threads   map[int]int64 // CPU ticks per mysqld thread ID
+	threadErr error
+
+	cpu    cpuTimes // aggregate "cpu" line of /proc/stat
+	cpuErr error
+
+	disk    diskStats // datadir block device
+	diskErr error
+
+	fsUsed map[string]uint64 // used bytes per mount point of mysqlFilesystems
+
+	net    map[string]int64 // snmp/netstat counters of mysqld's network namespace
+	netErr error
+}
+
+// window is a pair of snapshots taken sampleSeconds apart. All rate-based
+// host checks are computed from the same window so they describe the same
+// period of time.
+type window struct {
+	pid           int
+	pidErr        error
+	diskDev       string // "major:minor" of the datadir device
+	diskName      string
+	diskErr       error
+	filesystems   []mysqlFilesystem
+	netDir        string // /proc/<pid>/net, or /proc/net if mysqld was not found
+	before, after snapshot
+}
+
+func (w *window) elapsed() float64 {
+	return w.after.at.Sub(w.before.at).Seconds()
+}
+
+// sampleWindow snapshots the host and the counters of mysqld process pid,
+// sleeps for sampleSeconds and snapshots them again. NewEnv takes one window
+// per run, so that all categories share it and the sleep.
+func sampleWindow(m *db.MySQL, pid int, pidErr error, sampleSeconds int) *window {
+	w := &window{netDir: "/proc/net", pid: pid, pidErr: pidErr}
+	if w.pid > 0 {
+		w.netDir = fmt.Sprintf("/proc/%d/net", w.pid)
+	}
+	w.diskDev, w.diskErr = dataDevice(m)
+	w.filesystems = mysqlFilesystems(m)
+	w.before = w.takeSnapshot()
+	time.Sleep(time.Duration(sampleSeconds) * time.Second)
+	w.after = w.takeSnapshot()
+	return w
+}
+
+func (w *window) takeSnapshot() snapshot {
+	s := snapshot{at: time.Now()}
+	if w.pid > 0 {
+		s.procUser, s.procSystem, s.procErr = readProcCPUTicks(fmt.Sprintf("/proc/%d/stat", w.pid))
+		s.threads, s.threadErr = readThreadTicks(w.pid)
+	}
+	s.cpu, s.cpuErr = readCPUTimes()
+	if w.diskDev != "" {
+		s.disk, w.diskName, s.diskErr = readDiskStats(w.diskDev)
+	}
+	s.fsUsed = make(map[string]uint64)
+	for _, fs := range w.filesystems {
+		if u, err := readFSUsage(fs.path); err == nil {
+			s.fsUsed[fs.mount] = u.used
+		}
+	}
+	s.net, s.netErr = readNetCounters(w.netDir)
+	return s
+}
+
+// cpuTimes are the host-wide CPU counters from /proc/stat, in clock ticks.
+type cpuTimes struct {
+	user, nice, system, idle, iowait, irq, softirq, steal int64
+}
+
+func (t cpuTimes) total() int64 {
+	return t.user + t.nice + t.system + t.idle + t.iowait + t.irq + t.softirq + t.steal
+}
+
+func readCPUTimes() (cpuTimes, error) {
+	var t cpuTimes
+	data, err := host.ReadFile("/proc/stat")
+	if err != nil {
+		return t, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[0] != "cpu" {
+			continue
+		}
+		vals := make([]int64, 8)
+		for i := range vals {
+			vals[i], _ = strconv.ParseInt(fields[i+1], 10, 64)
+		}
+		t = cpuTimes{vals[0], vals[1], vals[2], vals[3], vals[4], vals[5], vals[6], vals[7]}
+		return t, nil
+	}
+	return t, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+// hostCPUShare returns the share of host CPU time spent in field during the
+// window, in percent.
+func (w *window) hostCPUShare(field func(cpuTimes) int64) (float64, error) {
+	if w.before.cpuErr != nil {
+		return 0, w.before.cpuErr
+	}
+	if w.after.cpuErr != nil {
+		return 0, w.after.cpuErr
+	}
+	total := w.after.cpu.total() - w.before.cpu.total()
+	if total <= 0 {
+		return 0, fmt.Errorf("no CPU time elapsed in /proc/stat")
+	}
+	return float64(field(w.after.cpu)-field(w.before.cpu)) * 100.0 / float64(total), nil
+}
+
+// numCPU returns the CPUs mysqld may be scheduled on: its affinity mask if
+// pid is known, otherwise the host's online CPUs.
+func numCPU(pid int) int {
+	if pid > 0 {
+		if n, err := host.CPUAffinity(pid); err == nil && n > 0 {
+			return n
+		}
+	}
+	return host.OnlineCPUs()
+}
@@ -0,0 +1,211 @@
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+)
+
+// semiSyncPrefix returns the infix of the semi-sync variables for one side
+// ("source" or "replica"): the names introduced by the MySQL 8.0.26
+// rpl_semi_sync_source/replica plugins, or the older master/slave ones that
+// MariaDB still uses. It is empty when the plugin is not loaded.
+func semiSyncPrefix(m *db.MySQL, side string) string {
+	names := []string{"source", "master"}
+	if side == "replica" {
+		names = []string{"replica", "slave"}
+	}
+	for _, n := range names {
+		if _, ok := m.Vars["rpl_semi_sync_"+n+"_enabled"]; ok {
+			return n
+		}
+	}
+	return ""
+}
+
+// semiSyncChecks reports semi-synchronous replication on servers with the
+// source or replica plugin loaded. It returns nothing otherwise.
+func semiSyncChecks(m *db.MySQL) []Check {
+	source, replica := semiSyncPrefix(m, "source"), semiSyncPrefix(m, "replica")
+	var fns []func() Check
+	if source != "" {
+		fns = append(fns, func() Check { return checkSemiSyncSource(m, source) })
+		if strings.EqualFold(m.Vars["rpl_semi_sync_"+source+"_enabled"], "ON") {
+			fns = append(fns,
+				func() Check { return checkSemiSyncClients(m, source) },
+				func() Check { return checkSemiSyncAcks(m, source) },
+				func() Check { return checkSemiSyncWait(m, source) },
+			)
+		}
+	}
+	if replica != "" {
+		fns = append(fns, func() Check { return checkSemiSyncReplica(m, replica) })
+	}
+	return runAll(fns...)
+}
+
+func checkSemiSyncSource(m *db.MySQL, p string) Check {
+	c := Check{
+		Name:        "Semi-Sync Source",
+		Threshold:   "disabled, or enabled and active, OK; enabled but inactive CRIT",
+		Description: "Whether semi-synchronous replication is enabled on this source and currently in effect.",
+		Detail: "With semi-sync a commit returns only after at least one replica " +
+			"acknowledged receiving it, so a failover to that replica loses no " +
+			"committed transaction. When no replica acknowledges within " +
+			"rpl_semi_sync_source_timeout the source silently falls back to " +
+			"asynchronous replication (Rpl_semi_sync_source_status OFF) and commits " +
+			"are no longer protected until a replica catches up.",
+	}
+
+	enabled := m.Vars["rpl_semi_sync_"+p+"_enabled"]
+	if !strings.EqualFold(enabled, "ON") {
+		c.Value = "disabled"
+		c.Level = LevelOK
+		return c
+	}
+	status := m.Status["Rpl_semi_sync_"+p+"_status"]
+	c.Value = "enabled, status " + status
+	if wp := m.Vars["rpl_semi_sync_"+p+"_wait_point"]; wp != "" {
+		c.Value += ", wait point " + wp
+	}
+	if strings.EqualFold(status, "ON") {
+		c.Level = LevelOK
+	} else {
+		c.Value += " (fell back to async)"
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkSemiSyncClients(m *db.MySQL, p string) Check {
+	c := Check{
+		Name:        "Semi-Sync Clients",
+		Threshold:   ">= required acknowledgements OK, fewer WARN, none CRIT",
+		Description: "Semi-sync replicas connected against the acknowledgements a commit waits for.",
+		Detail: "A commit waits for rpl_semi_sync_source_wait_for_replica_count " +
+			"acknowledgements (always 1 on MariaDB). With fewer semi-sync replicas " +
+			"connected every commit waits for the full timeout and the source falls " +
+			"back to async; with exactly that many, losing one replica has the same " +
+			"effect.",
+	}
+
+	key := "Rpl_semi_sync_" + p + "_clients"
+	if _, ok := m.Status[key]; !ok {
+		return skip(c, key+" not available")
+	}
+	clients := int(statusFloat(m, key))
+	waitFor := 1
+	if p == "source" {
+		waitFor = max(1, int(varFloat(m, "rpl_semi_sync_source_wait_for_replica_count")))
+	} else if _, ok := m.Vars["rpl_semi_sync_master_wait_for_slave_count"]; ok {
+		waitFor = max(1, int(varFloat(m, "rpl_semi_sync_master_wait_for_slave_count")))
+	}
+
+	c.Value = fmt.Sprintf("%d connected, %d required", clients, waitFor)
+	switch {
+	case clients == 0:
+		c.Level = LevelCrit
+	case clients < waitFor:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkSemiSyncAcks(m *db.MySQL, p string) Check {
+	c := Check{
+		Name:        "Semi-Sync Acknowledgements",
+		Threshold:   "< 1% unacknowledged OK, 1-10% WARN, > 10% CRIT",
+		Description: "Commits that were not acknowledged by a replica (no_tx against yes_tx) and fallbacks to async.",
+		Detail: "Rpl_semi_sync_source_no_tx counts commits made while semi-sync was off, " +
+			"i.e. transactions that may be lost on failover; yes_tx those acknowledged " +
+			"by a replica. Rpl_semi_sync_source_no_times counts how often the source " +
+			"fell back to async after a timeout. The counters cover the time since " +
+			"the server started, so the fallbacks are shown but not graded: a single " +
+			"one long ago would warn until the next restart. A source that is in " +
+			"async mode now is reported by Semi-Sync Source.",
+	}
+
+	yes := statusFloat(m, "Rpl_semi_sync_"+p+"_yes_tx")
+	no := statusFloat(m, "Rpl_semi_sync_"+p+"_no_tx")
+	fallbacks := statusFloat(m, "Rpl_semi_sync_"+p+"_no_times")
+	v, ok := pct(no, yes+no)
+	if !ok {
+		c.Value = "no commits"
+		c.Level = LevelOK
+		return c
+	}
+	c.Value = fmt.Sprintf("%s unacknowledged (%.0f no_tx, %.0f yes_tx), %.0f fallbacks to async since startup", fmtPct(v), no, yes, fallbacks)
+	switch {
+	case v > 10:
+		c.Level = LevelCrit
+	case v >= 1:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelOK
+	}
+	return c
+}
+
+func checkSemiSyncWait(m *db.MySQL, p string) Check {
+	c := Check{
+		Name:        "Semi-Sync Wait Time",
+		Threshold:   "< 10% of the timeout OK, 10-50% WARN, > 50% CRIT",
+		Description: "Average time a commit waited for a replica acknowledgement, against rpl_semi_sync_source_timeout.",
+		Detail: "Every semi-sync commit waits one network round trip to the fastest " +
+			"replica plus its write to the relay log. Waits approaching the timeout " +
+			"mean the source is close to falling back to async, and they add directly " +
+			"to commit latency.",
+	}
+
+	key := "Rpl_semi_sync_" + p + "_tx_avg_wait_time"
+	if _, ok := m.Status[key]; !ok {
+		return skip(c, key+" not available")
+	}
+	waitMs := statusFloat(m, key) / 1000
+	timeoutMs := varFloat(m, "rpl_semi_sync_"+p+"_timeout")
+	c.Value = fmt.Sprintf("avg %.2fms", waitMs)
+	if timeoutMs <= 0 {
+		c.Level = LevelOK
+		return c
+	}
+	c.Value += fmt.Sprintf(" of %.0fms timeout", timeoutMs)
+	v, _ := pct(waitMs, timeoutMs)
+	switch {
+	case v < 10:
+		c.Level = LevelOK
+	case v <= 50:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
+	}
+	return c
+}
+
+func checkSemiSyncReplica(m *db.MySQL, p string) Check {
+	c := Check{
+		Name:        "Semi-Sync Replica",
+		Threshold:   "disabled, or enabled and active, OK; enabled but inactive WARN",
+		Description: "Whether this replica acknowledges transactions to a semi-sync source.",
+		Detail: "A replica with semi-sync enabled reports Rpl_semi_sync_replica_status ON " +
+			"while its I/O thread is connected to a source that has semi-sync enabled. " +
+			"OFF means the I/O thread is stopped or the source is not semi-sync, so " +
+			"this replica does not protect the source's commits.",
+	}
+
+	if !strings.EqualFold(m.Vars["rpl_semi_sync_"+p+"_enabled"], "ON") {
+		c.Value = "disabled"
+		c.Level = LevelOK
+		return c
+	}
+	status := m.Status["Rpl_semi_sync_"+p+"_status"]
+	c.Value = "enabled, status " + status
+	if strings.EqualFold(status, "ON") {
+		c.Level = LevelOK
+	} else {
+		c.Level = LevelWarn
+	}
+	return c
+}
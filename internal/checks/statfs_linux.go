@@ -0,0 +1,36 @@
+package checks
+
+import (
+	"log/slog"
+	"syscall"
+	"time"
+)
+
+func statfs(path string, stat *syscall.Statfs_t) error {
+	start := time.Now()
+	err := syscall.Statfs(path, stat)
+	slog.Debug("statfs", "path", path, "duration", time.Since(start), "err", err)
+	return err
+}
+
+func readFSUsage(path string) (fsUsage, error) {
+	var st syscall.Statfs_t
+	if err := statfs(path, &st); err != nil {
+		return fsUsage{}, err
+	}
+	bsize := uint64(st.Frsize)
+	if bsize == 0 {
+		bsize = uint64(st.Bsize)
+	}
+	u := fsUsage{
+		used:     (st.Blocks - st.Bfree) * bsize,
+		avail:    st.Bavail * bsize,
+		reserved: (st.Bfree - st.Bavail) * bsize,
+		inodes:   st.Files,
+	}
+	u.size = u.used + u.avail
+	if st.Files > 0 {
+		u.inodesUsed = st.Files - st.Ffree
+	}
+	return u, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package checks
+
+import "errors"
+
+// readFSUsage is only implemented on Linux, the platform the host checks
+// target.
+func readFSUsage(path string) (fsUsage, error) {
+	return fsUsage{}, errors.ErrUnsupported
+}
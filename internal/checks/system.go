@@ -1,69 +1,117 @@
 package checks
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
-	"time"
 
 	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
 )
 
-func RunSystemChecks(m *db.MySQL, sampleSeconds int) []Check {
-	var results []Check
-	results = append(results, checkCPU(sampleSeconds))
-	results = append(results, checkDiskSpace(m))
-	results = append(results, checkMemory())
-	results = append(results, checkConnectionUtilization(m))
-	results = append(results, checkOpenFiles(m))
-	return results
+// SystemOptions configure the host checks.
+type SystemOptions struct {
+	// SampleSeconds is the length of the window over which rates (CPU, disk
+	// I/O, filesystem growth) are measured.
+	SampleSeconds int
+	// HistoryPath is a file in which filesystem usage is kept between runs
+	// for the disk-full forecast. Empty disables it.
+	HistoryPath string
 }
 
-func checkCPU(sampleSeconds int) Check {
+func RunSystemChecks(m *db.MySQL, h *host.Info, e *Env, opts SystemOptions) []Check {
+	if _, skipped := h.HostChecksSkipped(); skipped {
+		results := runAll(
+			func() Check { return checkRemoteHost(h) },
+			func() Check { return checkSQLMemory(m) },
+			func() Check { return checkSQLDataSize(m) },
+			func() Check { return checkUptime(m, h) },
+			func() Check { return checkConnectionUtilization(m) },
+			func() Check { return checkOpenFiles(m) },
+		)
+		if m.DryRun() {
+			// The host checks are not run in dry-run mode. Record the one
+			// statement they issue, with a placeholder for the thread IDs
+			// that come from the CPU sample.
+			m.QueryRows(threadsQuery([]string{"?"}))
+		}
+		return results
+	}
+	w := e.window
+	results := runAll(
+		func() Check { return checkCPU(h, w) },
+		func() Check { return checkThreadCPU(m, h, w) },
+		func() Check { return checkCPUIOWait(h, w) },
+		func() Check { return checkCPUSteal(h, w) },
+	)
+	for _, spec := range pressureSpecs {
+		results = append(results, runAll(func() Check { return checkPressure(h, e, spec) })...)
+	}
+	results = append(results, runMany(func() []Check { return checkDiskSpace(m) })...)
+	results = append(results, runMany(func() []Check { return checkDiskForecast(m, w, opts.HistoryPath) })...)
+	return append(results, runAll(
+		func() Check { return checkDataDiskIO(h, w) },
+		func() Check { return checkMemory(h, e) },
+		func() Check { return checkMysqldMemory(m, h, e) },
+		func() Check { return checkMysqldSwap(h, e) },
+		func() Check { return checkHostSwap(h) },
+		func() Check { return checkOOMScoreAdj(h, e) },
+		func() Check { return checkOOMKills(h) },
+		func() Check { return checkUptime(m, h) },
+		func() Check { return checkConnectionUtilization(m) },
+		func() Check { return checkOpenFiles(m) },
+	)...)
+}
+
+func checkCPU(h *host.Info, w *window) Check {
 	c := Check{
 		Name:      "CPU Utilization",
 		Threshold: "<= 80% OK, 80-100% WARN, > 100% CRIT",
-		Description: "Average CPU usage by the mysqld process.",
+		Description: "Average CPU usage by the mysqld process, relative to the cores it may use.",
 		Detail: "CPU utilization measures how much processing power mysqld is consuming " +
 			"relative to the available cores. High sustained CPU usage (above 80%) may " +
 			"indicate poorly optimized queries, missing indexes, or that the server needs " +
-			"more processing capacity. Values above 100% indicate contention across cores.",
+			"more processing capacity. Values above 100% indicate contention across cores. " +
+			"The available cores are those in mysqld's CPU affinity mask, further limited " +
+			"by its cgroup CPU quota or cpuset. A high system share points at locking, " +
+			"memory allocation or I/O overhead in the kernel rather than query execution.",
 	}
 
-	pid, err := findMysqldPid()
-	if err != nil {
-		c.Value = "N/A"
-		c.Level = LevelSkip
-		return c
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
 	}
-
-	statPath := fmt.Sprintf("/proc/%d/stat", pid)
-	t1, err := readProcCPUTicks(statPath)
-	if err != nil {
-		c.Value = "N/A"
-		c.Level = LevelSkip
-		return c
+	if w.pidErr != nil {
+		return skip(c, w.pidErr.Error())
 	}
-
-	time.Sleep(time.Duration(sampleSeconds) * time.Second)
-
-	t2, err := readProcCPUTicks(statPath)
-	if err != nil {
-		c.Value = "N/A"
-		c.Level = LevelSkip
-		return c
+	if w.before.procErr != nil {
+		return skip(c, w.before.procErr.Error())
+	}
+	if w.after.procErr != nil {
+		return skip(c, w.after.procErr.Error())
+	}
+	elapsed := w.elapsed()
+	if elapsed <= 0 {
+		return skip(c, "empty sample window")
 	}
 
-	hz := sysconfCLKTCK()
-	delta := float64(t2-t1) / float64(hz)
-	cpuCount := numCPU()
-	usage := (delta / float64(sampleSeconds)) * 100.0 / float64(cpuCount)
+	hz := float64(host.ClockTicks())
+	userSec := float64(w.after.procUser-w.before.procUser) / hz
+	sysSec := float64(w.after.procSystem-w.before.procSystem) / hz
+	cores := float64(numCPU(w.pid))
+	limited := false
+	if cg, err := host.ReadCgroup(w.pid); err == nil {
+		if l := cg.CPULimit(); l > 0 && l < cores {
+			cores = l
+			limited = true
+		}
+	}
+	share := func(sec float64) float64 { return sec / elapsed * 100.0 / cores }
+	usage := share(userSec + sysSec)
 
-	c.Value = fmtPct(usage)
+	c.Value = fmt.Sprintf("%s (usr %s, sys %s)", fmtPct(usage), fmtPct(share(userSec)), fmtPct(share(sysSec)))
+	if limited {
+		c.Value = fmt.Sprintf("%s of %.1f cores (cgroup)", c.Value, cores)
+	}
 	switch {
 	case usage <= 80:
 		c.Level = LevelOK
@@ -75,66 +123,90 @@ func checkCPU(sampleSeconds int) Check {
 	return c
 }
 
-func checkDiskSpace(m *db.MySQL) Check {
+func checkCPUIOWait(h *host.Info, w *window) Check {
 	c := Check{
-		Name:      "Disk Space Usage",
-		Threshold: "< 80% OK, >= 80% WARN",
-		Description: "Percentage of used disk space on the MySQL data directory filesystem.",
-		Detail: "Monitors the filesystem where MySQL stores its data files. Running out of " +
-			"disk space can cause MySQL to crash, corrupt data, or refuse writes entirely. " +
-			"Proactive monitoring prevents catastrophic failures. Keep at least 20% free for " +
-			"operations like ALTER TABLE, binary logs, and temporary files.",
+		Name:        "CPU I/O Wait",
+		Threshold:   "< 10% OK, 10-25% WARN, > 25% CRIT",
+		Description: "Host-wide share of CPU time spent idle waiting for disk I/O.",
+		Detail: "iowait counts time in which a CPU had nothing to run because the tasks " +
+			"it could run were blocked on disk I/O. Sustained high iowait on a database " +
+			"host means storage cannot keep up: expect slow queries, pending InnoDB I/O " +
+			"and checkpoint stalls. Measured from /proc/stat over the same sample window " +
+			"as CPU Utilization.",
 	}
 
-	datadir, ok := m.Vars["datadir"]
-	if !ok || datadir == "" {
-		c.Value = "N/A"
-		c.Level = LevelSkip
-		return c
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	v, err := w.hostCPUShare(func(t cpuTimes) int64 { return t.iowait })
+	if err != nil {
+		return skip(c, err.Error())
 	}
 
-	var stat syscall.Statfs_t
-	if err := syscall.Statfs(datadir, &stat); err != nil {
-		c.Value = "N/A"
-		c.Level = LevelSkip
-		return c
+	c.Value = fmtPct(v)
+	switch {
+	case v < 10:
+		c.Level = LevelOK
+	case v <= 25:
+		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
 	}
+	return c
+}
 
-	total := stat.Blocks * uint64(stat.Bsize)
-	free := stat.Bfree * uint64(stat.Bsize)
-	if total == 0 {
-		c.Value = "N/A"
-		c.Level = LevelSkip
-		return c
+func checkCPUSteal(h *host.Info, w *window) Check {
+	c := Check{
+		Name:        "CPU Steal Time",
+		Threshold:   "< 5% OK, 5-10% WARN, > 10% CRIT",
+		Description: "Host-wide share of CPU time taken by the hypervisor for other guests.",
+		Detail: "On virtual machines, steal time is time the guest wanted to run but the " +
+			"hypervisor gave the physical CPU to another tenant. It makes every query " +
+			"slower without showing up as load inside MySQL. Persistent steal above a few " +
+			"percent calls for a larger or dedicated instance type, or a move away from " +
+			"burstable instances that have exhausted their CPU credits.",
 	}
-	used := total - free
-	usage := float64(used) * 100.0 / float64(total)
 
-	c.Value = fmtPct(usage)
-	if usage < 80 {
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	v, err := w.hostCPUShare(func(t cpuTimes) int64 { return t.steal })
+	if err != nil {
+		return skip(c, err.Error())
+	}
+
+	c.Value = fmtPct(v)
+	switch {
+	case v < 5:
 		c.Level = LevelOK
-	} else {
+	case v <= 10:
 		c.Level = LevelWarn
+	default:
+		c.Level = LevelCrit
 	}
 	return c
 }
 
-func checkMemory() Check {
+func checkMemory(h *host.Info, e *Env) Check {
 	c := Check{
 		Name:      "Memory Utilization",
 		Threshold: "< 80% OK, >= 80% WARN",
-		Description: "Current memory usage of the server.",
+		Description: "Current memory usage of the server, or of mysqld's cgroup when it has a memory limit.",
 		Detail: "Measures how much of the server's physical RAM is in use. MySQL relies " +
 			"heavily on memory for the InnoDB buffer pool, thread stacks, sort buffers, and " +
 			"caches. If memory utilization consistently exceeds 80%, the server may start " +
-			"swapping to disk, which drastically reduces database performance.",
+			"swapping to disk, which drastically reduces database performance. In a " +
+			"container the cgroup working set (usage minus reclaimable page cache) is " +
+			"compared with the memory limit, since that is where the OOM killer acts.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
 	}
 
 	memTotal, memAvail, err := readMeminfo()
 	if err != nil {
-		c.Value = "N/A"
-		c.Level = LevelSkip
-		return c
+		return skip(c, err.Error())
 	}
 
 	if memTotal == 0 {
@@ -144,8 +216,16 @@ func checkMemory() Check {
 	}
 	used := memTotal - memAvail
 	usage := float64(used) * 100.0 / float64(memTotal)
-
 	c.Value = fmtPct(usage)
+
+	// Without mysqld's pid its cgroup is unknown; ReadCgroup(0) would
+	// describe this process instead.
+	if pid, err := e.mysqldPid(); err != nil {
+		c.Value += " (host; mysqld not found, cgroup limit not checked)"
+	} else if cg, err := host.ReadCgroup(pid); err == nil && cg.MemoryLimit > 0 && cg.MemoryLimit < memTotal {
+		usage = float64(cg.WorkingSet()) * 100.0 / float64(cg.MemoryLimit)
+		c.Value = fmt.Sprintf("%s of %s (cgroup)", fmtPct(usage), fmtBytes(float64(cg.MemoryLimit)))
+	}
 	if usage < 80 {
 		c.Level = LevelOK
 	} else {
@@ -248,95 +328,57 @@ func varFloat(m *db.MySQL, key string) float64 {
 	return f
 }
 
-func findMysqldPid() (int, error) {
-	entries, err := os.ReadDir("/proc")
+// readProcCPUTicks returns utime and stime from /proc/<pid>/stat. Fields
+// are counted from the closing parenthesis of comm, which may contain
+// spaces.
+func readProcCPUTicks(path string) (utime, stime int64, err error) {
+	data, err := host.ReadFile(path)
 	if err != nil {
-		return 0, err
-	}
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
-		}
-		pid, err := strconv.Atoi(e.Name())
-		if err != nil {
-			continue
-		}
-		comm, err := os.ReadFile(filepath.Join("/proc", e.Name(), "comm"))
-		if err != nil {
-			continue
-		}
-		if strings.TrimSpace(string(comm)) == "mysqld" {
-			return pid, nil
-		}
+		return 0, 0, err
 	}
-	return 0, fmt.Errorf("mysqld process not found")
-}
-
-func readProcCPUTicks(path string) (int64, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return 0, err
+	s := string(data)
+	idx := strings.LastIndexByte(s, ')')
+	if idx < 0 {
+		return 0, 0, fmt.Errorf("unexpected /proc/pid/stat format")
 	}
-	fields := strings.Fields(string(data))
-	if len(fields) < 15 {
-		return 0, fmt.Errorf("unexpected /proc/pid/stat format")
+	fields := strings.Fields(s[idx+1:])
+	// fields[0] is state (field 3); utime and stime are fields 14 and 15.
+	if len(fields) < 13 {
+		return 0, 0, fmt.Errorf("unexpected /proc/pid/stat format")
 	}
-	utime, err := strconv.ParseInt(fields[13], 10, 64)
+	utime, err = strconv.ParseInt(fields[11], 10, 64)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
-	stime, err := strconv.ParseInt(fields[14], 10, 64)
+	stime, err = strconv.ParseInt(fields[12], 10, 64)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
-	return utime + stime, nil
-}
-
-func sysconfCLKTCK() int {
-	return 100
+	return utime, stime, nil
 }
 
-func numCPU() int {
-	data, err := os.ReadFile("/proc/cpuinfo")
+// effectiveMemory returns the memory available to mysqld: the host's RAM,
+// or its cgroup memory limit if that is lower. The host's RAM is used when
+// the mysqld process cannot be found.
+func effectiveMemory(e *Env) (uint64, error) {
+	total, _, err := readMeminfo()
 	if err != nil {
-		return 1
+		return 0, err
 	}
-	count := 0
-	for _, line := range strings.Split(string(data), "\n") {
-		if strings.HasPrefix(line, "processor") {
-			count++
-		}
+	pid, err := e.mysqldPid()
+	if err != nil {
+		return total, nil
 	}
-	if count == 0 {
-		return 1
+	if cg, err := host.ReadCgroup(pid); err == nil && cg.MemoryLimit > 0 && cg.MemoryLimit < total {
+		return cg.MemoryLimit, nil
 	}
-	return count
+	return total, nil
 }
 
 func readMeminfo() (total, available uint64, err error) {
-	f, err := os.Open("/proc/meminfo")
+	fields, err := readKBFields("/proc/meminfo")
 	if err != nil {
 		return 0, 0, err
 	}
-	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "MemTotal:") {
-			total = parseKB(line)
-		} else if strings.HasPrefix(line, "MemAvailable:") {
-			available = parseKB(line)
-		}
-	}
-	return total, available, scanner.Err()
-}
-
-func parseKB(line string) uint64 {
-	fields := strings.Fields(line)
-	if len(fields) < 2 {
-		return 0
-	}
-	v, _ := strconv.ParseUint(fields[1], 10, 64)
-	return v * 1024
+	return fields["MemTotal"], fields["MemAvailable"], nil
 }
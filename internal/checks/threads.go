@@ -0,0 +1,159 @@
+package checks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
+)
+
+// hotThreads is how many of the busiest mysqld threads are reported.
+const hotThreads = 5
+
+// readThreadTicks returns the CPU time (utime+stime, in clock ticks) of
+// every thread of pid, keyed by thread ID.
+func readThreadTicks(pid int) (map[int]int64, error) {
+	dir := fmt.Sprintf("/proc/%d/task", pid)
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ticks := make(map[int]int64, len(entries))
+	for _, e := range entries {
+		tid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// Threads may exit between listing and reading.
+		if utime, stime, err := readProcCPUTicks(fmt.Sprintf("%s/%d/stat", dir, tid)); err == nil {
+			ticks[tid] = utime + stime
+		}
+	}
+	return ticks, nil
+}
+
+// threadCPU is one thread's CPU usage over the sample window, in percent of
+// one core.
+type threadCPU struct {
+	tid int
+	pct float64
+}
+
+func checkThreadCPU(m *db.MySQL, h *host.Info, w *window) Check {
+	c := Check{
+		Name:        "mysqld Thread CPU",
+		Threshold:   "busiest thread < 90% of a core OK, >= 90% WARN",
+		Description: "The mysqld threads that used the most CPU during the sample, with what they were doing.",
+		Detail: "Per-thread CPU time is sampled from /proc/<pid>/task/*/stat over the same " +
+			"window as CPU Utilization and joined on THREAD_OS_ID to " +
+			"performance_schema.threads, which names background threads (purge, page " +
+			"cleaner, replication applier) and gives the connection, user and current " +
+			"statement of foreground threads. A single thread near 100% of a core is a " +
+			"serialisation point that more cores will not help: a long query, a busy " +
+			"replication applier or purge falling behind.",
+	}
+
+	if reason, bad := h.ProcUnreliable(); bad {
+		return skip(c, reason)
+	}
+	if w.pidErr != nil {
+		return skip(c, w.pidErr.Error())
+	}
+	if w.before.threadErr != nil {
+		return skip(c, w.before.threadErr.Error())
+	}
+	if w.after.threadErr != nil {
+		return skip(c, w.after.threadErr.Error())
+	}
+	elapsed := w.elapsed()
+	if elapsed <= 0 {
+		return skip(c, "no time elapsed")
+	}
+
+	var busy []threadCPU
+	ticks := float64(host.ClockTicks())
+	for tid, after := range w.after.threads {
+		delta := after - w.before.threads[tid]
+		if delta <= 0 {
+			continue
+		}
+		busy = append(busy, threadCPU{tid: tid, pct: float64(delta) / ticks / elapsed * 100})
+	}
+	if len(busy) == 0 {
+		c.Value = fmt.Sprintf("no thread used CPU in %.0fs", elapsed)
+		c.Level = LevelOK
+		return c
+	}
+	sort.Slice(busy, func(i, j int) bool { return busy[i].pct > busy[j].pct })
+	if len(busy) > hotThreads {
+		busy = busy[:hotThreads]
+	}
+
+	names := threadNames(m, w.pid, busy)
+	parts := make([]string, len(busy))
+	for i, t := range busy {
+		parts[i] = fmt.Sprintf("tid %d %s %s", t.tid, fmtPct(t.pct), names[t.tid])
+	}
+	c.Value = strings.Join(parts, "; ")
+	if busy[0].pct >= 90 {
+		c.Level = LevelWarn
+	} else {
+		c.Level = LevelOK
+	}
+	return c
+}
+
+// threadsQuery selects the performance_schema.threads rows of the given
+// kernel thread IDs.
+func threadsQuery(ids []string) string {
+	return "SELECT THREAD_OS_ID, NAME, PROCESSLIST_ID, PROCESSLIST_USER, PROCESSLIST_HOST, " +
+		"PROCESSLIST_COMMAND, LEFT(PROCESSLIST_INFO, 80) AS INFO " +
+		"FROM performance_schema.threads WHERE THREAD_OS_ID IN (" + strings.Join(ids, ",") + ")"
+}
+
+// threadNames describes each thread from performance_schema.threads, or
+// by its kernel thread name when performance_schema cannot be used.
+func threadNames(m *db.MySQL, pid int, threads []threadCPU) map[int]string {
+	names := make(map[int]string, len(threads))
+	ids := make([]string, len(threads))
+	for i, t := range threads {
+		ids[i] = strconv.Itoa(t.tid)
+		if comm, err := host.ReadFile(fmt.Sprintf("/proc/%d/task/%d/comm", pid, t.tid)); err == nil {
+			names[t.tid] = "(" + strings.TrimSpace(string(comm)) + ")"
+		}
+	}
+
+	if _, missing := missingPrivilege(m, db.ReqPerformanceSchema); missing {
+		return names
+	}
+	if _, busy := m.Overloaded(); busy {
+		return names
+	}
+	rows, err := m.QueryRows(threadsQuery(ids))
+	if err != nil {
+		return names
+	}
+	for _, r := range rows {
+		tid, err := strconv.Atoi(r["THREAD_OS_ID"])
+		if err != nil {
+			continue
+		}
+		// thread/innodb/page_flush_coordinator_thread -> page_flush_coordinator_thread
+		name := r["NAME"]
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		if r["PROCESSLIST_ID"] != "" && r["PROCESSLIST_USER"] != "" {
+			name = fmt.Sprintf("conn %s %s@%s %s", r["PROCESSLIST_ID"], r["PROCESSLIST_USER"],
+				r["PROCESSLIST_HOST"], r["PROCESSLIST_COMMAND"])
+			if info := strings.Join(strings.Fields(r["INFO"]), " "); info != "" {
+				name += ": " + info
+			}
+		}
+		names[tid] = name
+	}
+	return names
+}
@@ -2,11 +2,17 @@ package config
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
+// PlatformCnfPath is the credentials file provisioned on hosting-platform
+// servers. It is the last entry of the discovery chain.
+const PlatformCnfPath = "/data/web/.my.cnf"
+
 type MySQLConfig struct {
 	User     string
 	Password string
@@ -14,6 +20,7 @@ type MySQLConfig struct {
 	Port     string
 	Socket   string
 	Database string
+	SSLMode  string
 }
 
 func ParseMyCnf(path string) (*MySQLConfig, error) {
@@ -64,6 +71,8 @@ func ParseMyCnf(path string) (*MySQLConfig, error) {
 			cfg.Socket = val
 		case "database":
 			cfg.Database = val
+		case "ssl-mode", "ssl_mode":
+			cfg.SSLMode = strings.ToUpper(val)
 		}
 	}
 	if err := scanner.Err(); err != nil {
@@ -76,13 +85,82 @@ func ParseMyCnf(path string) (*MySQLConfig, error) {
 	return cfg, nil
 }
 
+// CnfSearchPaths returns the locations probed when no -cnf flag is given,
+// in order of preference. Entries that cannot be resolved (e.g. an unset
+// $MYSQL_HOME) are left out.
+func CnfSearchPaths() []string {
+	var paths []string
+	if home := os.Getenv("MYSQL_HOME"); home != "" {
+		paths = append(paths, filepath.Join(home, "my.cnf"))
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		paths = append(paths, filepath.Join(home, ".my.cnf"))
+	}
+	paths = append(paths,
+		"/etc/mysql/debian.cnf",
+		"/etc/my.cnf",
+		"/etc/mysql/my.cnf",
+		PlatformCnfPath,
+	)
+	return paths
+}
+
+// DiscoverMyCnf walks CnfSearchPaths and returns the first file that exists
+// and has a usable [client] section, together with its path. Files that
+// exist but lack a user (e.g. a server-only /etc/my.cnf) are passed over.
+func DiscoverMyCnf() (*MySQLConfig, string, error) {
+	paths := CnfSearchPaths()
+	var skipped []string
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		cfg, err := ParseMyCnf(p)
+		if err != nil {
+			skipped = append(skipped, err.Error())
+			continue
+		}
+		return cfg, p, nil
+	}
+	msg := fmt.Sprintf("no usable cnf file found (searched: %s)", strings.Join(paths, ", "))
+	if len(skipped) > 0 {
+		msg += "; " + strings.Join(skipped, "; ")
+	}
+	return nil, "", errors.New(msg)
+}
+
 func (c *MySQLConfig) DSN() string {
 	db := c.Database
 	if db == "" {
 		db = "information_schema"
 	}
+	params := "timeout=10s"
+	if tls := c.tlsParam(); tls != "" {
+		params += "&tls=" + tls
+	}
 	if c.Socket != "" {
-		return fmt.Sprintf("%s:%s@unix(%s)/%s?timeout=10s", c.User, c.Password, c.Socket, db)
+		return fmt.Sprintf("%s:%s@unix(%s)/%s?%s", c.User, c.Password, c.Socket, db, params)
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s", c.User, c.Password, c.Host, c.Port, db, params)
+}
+
+// tlsParam maps the mysql client's ssl-mode option onto the driver's tls
+// DSN parameter. REQUIRED encrypts without verifying, like the mysql client.
+// VERIFY_CA uses the "verify-ca" config registered by the db package, which
+// checks the certificate chain but not the host name.
+func (c *MySQLConfig) tlsParam() string {
+	switch c.SSLMode {
+	case "DISABLED":
+		return "false"
+	case "PREFERRED":
+		return "preferred"
+	case "REQUIRED":
+		return "skip-verify"
+	case "VERIFY_CA":
+		return "verify-ca"
+	case "VERIFY_IDENTITY":
+		return "true"
+	default:
+		return ""
 	}
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?timeout=10s", c.User, c.Password, c.Host, c.Port, db)
 }
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ConnectErrorKind classifies why a connection attempt failed, so callers
+// can tell "MySQL is down" apart from "the credentials are wrong".
+type ConnectErrorKind int
+
+const (
+	ConnErrUnknown ConnectErrorKind = iota
+	ConnErrAuth
+	ConnErrNetwork
+	ConnErrSocket
+	ConnErrTooManyConnections
+	ConnErrTLS
+	ConnErrTimeout
+	ConnErrHostBlocked
+)
+
+func (k ConnectErrorKind) String() string {
+	switch k {
+	case ConnErrAuth:
+		return "auth_denied"
+	case ConnErrNetwork:
+		return "network"
+	case ConnErrSocket:
+		return "socket_missing"
+	case ConnErrTooManyConnections:
+		return "too_many_connections"
+	case ConnErrTLS:
+		return "tls_mismatch"
+	case ConnErrTimeout:
+		return "timeout"
+	case ConnErrHostBlocked:
+		return "host_blocked"
+	default:
+		return "unknown"
+	}
+}
+
+// Retryable reports whether another attempt may succeed without operator
+// intervention. Bad credentials or TLS settings will not fix themselves, and
+// a blocked host stays blocked until FLUSH HOSTS.
+func (k ConnectErrorKind) Retryable() bool {
+	switch k {
+	case ConnErrNetwork, ConnErrSocket, ConnErrTooManyConnections, ConnErrTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConnectError is returned by Connect when the server could not be reached
+// or refused the session.
+type ConnectError struct {
+	Kind     ConnectErrorKind
+	Code     uint16 // MySQL error number, 0 if the failure was not a server error
+	Attempts int
+	Err      error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("failed to connect to mysql (%s): %v", e.Kind, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+func classifyConnectError(err error) *ConnectError {
+	ce := &ConnectError{Kind: ConnErrUnknown, Err: err}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		ce.Code = myErr.Number
+		switch myErr.Number {
+		case 1045, 1044, 1698:
+			// ER_ACCESS_DENIED_ERROR, ER_DBACCESS_DENIED_ERROR,
+			// ER_ACCESS_DENIED_NO_PASSWORD_ERROR
+			ce.Kind = ConnErrAuth
+		case 1040, 1203:
+			// ER_CON_COUNT_ERROR, ER_TOO_MANY_USER_CONNECTIONS
+			ce.Kind = ConnErrTooManyConnections
+		case 3159:
+			// ER_SECURE_TRANSPORT_REQUIRED
+			ce.Kind = ConnErrTLS
+		case 1130:
+			// ER_HOST_NOT_PRIVILEGED
+			ce.Kind = ConnErrAuth
+		case 1129:
+			// ER_HOST_IS_BLOCKED: too many failed handshakes from this
+			// host (max_connect_errors), not wrong credentials
+			ce.Kind = ConnErrHostBlocked
+		}
+		return ce
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var unknownAuth x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	if errors.Is(err, mysql.ErrNoTLS) || errors.As(err, &certErr) ||
+		errors.As(err, &unknownAuth) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &recordErr) || strings.Contains(err.Error(), "tls:") {
+		ce.Kind = ConnErrTLS
+		return ce
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		ce.Kind = ConnErrTimeout
+		return ce
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Net == "unix" && errors.Is(err, syscall.ENOENT) {
+		ce.Kind = ConnErrSocket
+		return ce
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.As(err, &opErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH) {
+		ce.Kind = ConnErrNetwork
+		return ce
+	}
+
+	return ce
+}
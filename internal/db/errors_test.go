@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassifyConnectError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantKind ConnectErrorKind
+		wantCode uint16
+	}{
+		{"access denied", &mysql.MySQLError{Number: 1045}, ConnErrAuth, 1045},
+		{"host not privileged", &mysql.MySQLError{Number: 1130}, ConnErrAuth, 1130},
+		{"too many connections", &mysql.MySQLError{Number: 1040}, ConnErrTooManyConnections, 1040},
+		{"user connection limit", &mysql.MySQLError{Number: 1203}, ConnErrTooManyConnections, 1203},
+		{"secure transport required", &mysql.MySQLError{Number: 3159}, ConnErrTLS, 3159},
+		{"host blocked", &mysql.MySQLError{Number: 1129}, ConnErrHostBlocked, 1129},
+		{"other server error", &mysql.MySQLError{Number: 1064}, ConnErrUnknown, 1064},
+		{"wrapped server error", fmt.Errorf("ping: %w", &mysql.MySQLError{Number: 1045}), ConnErrAuth, 1045},
+		{"server without TLS", mysql.ErrNoTLS, ConnErrTLS, 0},
+		{"unknown authority", x509.UnknownAuthorityError{}, ConnErrTLS, 0},
+		{"deadline", context.DeadlineExceeded, ConnErrTimeout, 0},
+		{
+			"dial timeout",
+			&net.OpError{Op: "dial", Net: "tcp", Err: &timeoutError{}},
+			ConnErrTimeout, 0,
+		},
+		{
+			"missing socket",
+			&net.OpError{Op: "dial", Net: "unix", Err: os.NewSyscallError("connect", syscall.ENOENT)},
+			ConnErrSocket, 0,
+		},
+		{
+			"connection refused",
+			&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			ConnErrNetwork, 0,
+		},
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "db.invalid"}, ConnErrNetwork, 0},
+		{"host unreachable", syscall.EHOSTUNREACH, ConnErrNetwork, 0},
+		{"other", errors.New("something else"), ConnErrUnknown, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ce := classifyConnectError(tt.err)
+			if ce.Kind != tt.wantKind || ce.Code != tt.wantCode {
+				t.Errorf("classifyConnectError(%v) = %s (code %d), want %s (code %d)",
+					tt.err, ce.Kind, ce.Code, tt.wantKind, tt.wantCode)
+			}
+			if !errors.Is(ce, tt.err) {
+				t.Errorf("classifyConnectError(%v) does not wrap the original error", tt.err)
+			}
+		})
+	}
+}
+
+// timeoutError is a net.Error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -1,35 +1,108 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
 	"github.com/hpowernl/MySQL_check/internal/config"
 )
 
+// ErrDryRun is returned by every query in dry-run mode.
+var ErrDryRun = errors.New("dry run: statement not executed")
+
+// ErrStatementTimeout is returned when a statement passed its client-side
+// deadline. The driver closes the connection, and with it the session
+// settings, so every later statement fails with the same error; see Err.
+var ErrStatementTimeout = errors.New("statement timed out")
+
 type MySQL struct {
-	db      *sql.DB
-	Status  map[string]string
-	Vars    map[string]string
-	Version string
+	db         *sql.DB
+	conn       *sql.Conn
+	Status     map[string]string
+	Vars       map[string]string
+	Version    string
+	Privileges *Privileges
+	Limits     SessionLimits
+
+	dryRun     bool
+	statements []string
+	err        error // set when a statement timed out
 }
 
-func Connect(cfg *config.MySQLConfig) (*MySQL, error) {
-	conn, err := sql.Open("mysql", cfg.DSN())
+// Connect opens a session and verifies it with a ping. Retryable failures
+// are attempted up to retries more times, doubling the delay after each
+// attempt. Connection failures are returned as *ConnectError.
+//
+// All queries run on a single pinned connection so that the session
+// settings applied by Harden cover every statement the tool issues.
+func Connect(cfg *config.MySQLConfig, retries int, delay time.Duration) (*MySQL, error) {
+	pool, err := sql.Open("mysql", cfg.DSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open mysql: %w", err)
 	}
-	if err := conn.Ping(); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to connect to mysql: %w", err)
+	pool.SetMaxOpenConns(1)
+
+	for attempt := 1; ; attempt++ {
+		conn, err := pool.Conn(context.Background())
+		if err == nil {
+			err = conn.PingContext(context.Background())
+			if err == nil {
+				return &MySQL{db: pool, conn: conn}, nil
+			}
+			conn.Close()
+		}
+		ce := classifyConnectError(err)
+		ce.Attempts = attempt
+		if attempt > retries || !ce.Kind.Retryable() {
+			pool.Close()
+			return nil, ce
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// NewDryRun returns a MySQL that executes nothing and records every
+// statement it is asked to run; see Statements.
+func NewDryRun() *MySQL {
+	return &MySQL{
+		dryRun: true,
+		Status: map[string]string{},
+		Vars:   map[string]string{},
 	}
-	return &MySQL{db: conn}, nil
+}
+
+// Err returns the error that ended the session, or nil. The checks report
+// a failed statement as skipped; after a timeout the caller should discard
+// the results and report Err instead.
+func (m *MySQL) Err() error {
+	return m.err
+}
+
+// Statements returns the statements recorded in dry-run mode, in order and
+// without duplicates.
+func (m *MySQL) Statements() []string {
+	return m.statements
+}
+
+// DryRun reports whether statements are recorded instead of executed.
+// Checks use it to take branches that depend on server state, so that the
+// recording lists every statement the tool can issue.
+func (m *MySQL) DryRun() bool {
+	return m.dryRun
 }
 
 func (m *MySQL) Close() {
+	if m.conn != nil {
+		m.conn.Close()
+	}
 	if m.db != nil {
 		m.db.Close()
 	}
@@ -45,39 +118,177 @@ func (m *MySQL) LoadAll() error {
 	if err != nil {
 		return fmt.Errorf("SHOW GLOBAL VARIABLES: %w", err)
 	}
-	if err := m.db.QueryRow("SELECT VERSION()").Scan(&m.Version); err != nil {
+	// Harden has usually read the version already.
+	if m.Version != "" {
+		return nil
+	}
+	if err := m.scanRow("SELECT VERSION()", &m.Version); err != nil {
 		return fmt.Errorf("SELECT VERSION(): %w", err)
 	}
 	return nil
 }
 
 func (m *MySQL) loadKeyVal(query string) (map[string]string, error) {
-	rows, err := m.db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
 	result := make(map[string]string)
-	var k, v string
-	for rows.Next() {
-		if err := rows.Scan(&k, &v); err != nil {
-			return nil, err
+	err := m.query(query, func(rows *sql.Rows) error {
+		var k, v string
+		for rows.Next() {
+			if err := rows.Scan(&k, &v); err != nil {
+				return err
+			}
+			result[k] = v
 		}
-		result[k] = v
+		return nil
+	})
+	if errors.Is(err, ErrDryRun) {
+		return map[string]string{}, nil
 	}
-	return result, rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("sql rows", "query", query, "rows", len(result))
+	return result, nil
 }
 
 func (m *MySQL) QueryScalar(query string) (string, error) {
 	var val string
-	err := m.db.QueryRow(query).Scan(&val)
+	err := m.scanRow(query, &val)
 	if err != nil {
 		return "", err
 	}
 	return val, nil
 }
 
+// QueryRows returns every row of query as a map from column name to value.
+// NULL values are returned as empty strings.
+func (m *MySQL) QueryRows(query string) ([]map[string]string, error) {
+	var result []map[string]string
+	err := m.query(query, func(rows *sql.Rows) error {
+		cols, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+
+		vals := make([]sql.NullString, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		for rows.Next() {
+			if err := rows.Scan(ptrs...); err != nil {
+				return err
+			}
+			row := make(map[string]string, len(cols))
+			for i, col := range cols {
+				row[col] = vals[i].String
+			}
+			result = append(result, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("sql rows", "query", query, "rows", len(result))
+	return result, nil
+}
+
+// statementTimeoutMargin is added to Limits.MaxExecutionTime for the
+// client-side deadline, so that the server's own limit normally fires
+// first and reports a proper error.
+const statementTimeoutMargin = 5 * time.Second
+
+// statementContext bounds a statement on the client side. The server-side
+// limit only covers SELECT, and neither covers a server or network that
+// stops answering. A MaxExecutionTime of 0 disables both.
+func (m *MySQL) statementContext() (context.Context, context.CancelFunc) {
+	if m.Limits.MaxExecutionTime <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), m.Limits.MaxExecutionTime+statementTimeoutMargin)
+}
+
+// query runs a statement on the session connection and hands the result to
+// scan before the rows are closed. In dry-run mode the statement is
+// recorded instead and ErrDryRun is returned.
+func (m *MySQL) query(query string, scan func(*sql.Rows) error) error {
+	if m.dryRun {
+		m.record(query)
+		return ErrDryRun
+	}
+	if m.err != nil {
+		return m.err
+	}
+	ctx, cancel := m.statementContext()
+	defer cancel()
+	start := time.Now()
+	rows, err := m.conn.QueryContext(ctx, query)
+	slog.Debug("sql", "query", query, "duration", time.Since(start), "err", err)
+	if err != nil {
+		return m.checkDeadline(ctx, query, err)
+	}
+	defer rows.Close()
+	if err := scan(rows); err != nil {
+		return m.checkDeadline(ctx, query, err)
+	}
+	return m.checkDeadline(ctx, query, rows.Err())
+}
+
+// checkDeadline turns an error caused by the statement's client-side
+// deadline into ErrStatementTimeout and ends the session with it.
+func (m *MySQL) checkDeadline(ctx context.Context, query string, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	m.err = fmt.Errorf("%w after %s, connection closed: %s", ErrStatementTimeout,
+		m.Limits.MaxExecutionTime+statementTimeoutMargin, query)
+	return m.err
+}
+
+func (m *MySQL) exec(query string) error {
+	if m.dryRun {
+		m.record(query)
+		return nil
+	}
+	if m.err != nil {
+		return m.err
+	}
+	ctx, cancel := m.statementContext()
+	defer cancel()
+	start := time.Now()
+	_, err := m.conn.ExecContext(ctx, query)
+	slog.Debug("sql", "query", query, "duration", time.Since(start), "err", err)
+	return m.checkDeadline(ctx, query, err)
+}
+
+// scanRow scans the first row of query into dest, returning sql.ErrNoRows
+// if there is none.
+func (m *MySQL) scanRow(query string, dest ...any) error {
+	return m.query(query, func(rows *sql.Rows) error {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			return sql.ErrNoRows
+		}
+		return rows.Scan(dest...)
+	})
+}
+
+func (m *MySQL) record(query string) {
+	for _, s := range m.statements {
+		if s == query {
+			return
+		}
+	}
+	m.statements = append(m.statements, query)
+}
+
+// IsMariaDB reports whether the server identifies itself as MariaDB.
+func (m *MySQL) IsMariaDB() bool {
+	return strings.Contains(strings.ToLower(m.Version), "mariadb")
+}
+
 func (m *MySQL) VersionAtLeast(major, minor, patch int) bool {
 	v := m.Version
 	if idx := strings.Index(v, "-"); idx >= 0 {
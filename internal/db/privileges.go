@@ -0,0 +1,204 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Requirement is an access right that one or more checks depend on beyond
+// what SHOW GLOBAL STATUS / SHOW GLOBAL VARIABLES need.
+type Requirement int
+
+const (
+	ReqPerformanceSchema Requirement = iota
+	ReqSysSchema
+	ReqInformationSchema
+	ReqProcess
+	ReqReplicationClient
+)
+
+// AllRequirements lists every requirement used by the check suite, in the
+// order the grants subcommand prints them. information_schema is readable
+// by every account and cannot be granted, so it is probed but not listed.
+// REPLICATION SLAVE, which SHOW REPLICAS needs, is deliberately left out:
+// it lets an account stream the binary log, i.e. read every change. With
+// PROCESS, Connected Replicas counts the binlog dump threads instead.
+var AllRequirements = []Requirement{
+	ReqProcess,
+	ReqReplicationClient,
+	ReqPerformanceSchema,
+	ReqSysSchema,
+}
+
+// Privilege returns the privilege and object for a GRANT statement.
+func (r Requirement) Privilege() (priv, object string) {
+	switch r {
+	case ReqPerformanceSchema:
+		return "SELECT", "performance_schema.*"
+	case ReqSysSchema:
+		return "SELECT", "sys.*"
+	case ReqInformationSchema:
+		return "SELECT", "information_schema.*"
+	case ReqProcess:
+		return "PROCESS", "*.*"
+	case ReqReplicationClient:
+		return "REPLICATION CLIENT", "*.*"
+	default:
+		return "", ""
+	}
+}
+
+func (r Requirement) String() string {
+	priv, object := r.Privilege()
+	if object == "*.*" {
+		return priv
+	}
+	return priv + " on " + strings.TrimSuffix(object, ".*")
+}
+
+// Privileges is the result of the preflight: the raw grants and, for each
+// requirement that is not satisfied, the reason why.
+type Privileges struct {
+	Grants  []string
+	missing map[Requirement]string
+}
+
+// Missing returns a human-readable reason if req is not satisfied. Before a
+// preflight has run every requirement is assumed to be met, so checks fall
+// back to failing on their own queries.
+func (p *Privileges) Missing(req Requirement) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	reason, ok := p.missing[req]
+	return reason, ok
+}
+
+var globalGrantRe = regexp.MustCompile(`(?i)^GRANT (.+?) ON \*\.\* TO `)
+
+// Preflight inspects SHOW GRANTS and probes the schemas the checks read,
+// storing the outcome in m.Privileges.
+func (m *MySQL) Preflight() error {
+	p := &Privileges{missing: make(map[Requirement]string)}
+	m.Privileges = p
+
+	grantsErr := m.loadGrants(p)
+	if grantsErr == nil {
+		global := globalPrivileges(p.Grants)
+		if !global["PROCESS"] && !global["ALL PRIVILEGES"] {
+			p.missing[ReqProcess] = "requires " + ReqProcess.String()
+		}
+		if !hasAny(global, "REPLICATION CLIENT", "SUPER", "ALL PRIVILEGES",
+			"BINLOG MONITOR", "REPLICA MONITOR", "SLAVE MONITOR") {
+			p.missing[ReqReplicationClient] = "requires " + ReqReplicationClient.String()
+		}
+	}
+
+	probes := []struct {
+		req   Requirement
+		query string
+	}{
+		{ReqPerformanceSchema, "SELECT 1 FROM performance_schema.events_statements_history LIMIT 1"},
+		{ReqSysSchema, "SELECT 1 FROM sys.version LIMIT 1"},
+		{ReqInformationSchema, "SELECT 1 FROM information_schema.TABLES LIMIT 1"},
+	}
+	for _, pr := range probes {
+		if reason := m.probe(pr.req, pr.query); reason != "" {
+			p.missing[pr.req] = reason
+		}
+	}
+	if grantsErr != nil && !errors.Is(grantsErr, ErrDryRun) {
+		return fmt.Errorf("SHOW GRANTS: %w", grantsErr)
+	}
+	return nil
+}
+
+func (m *MySQL) loadGrants(p *Privileges) error {
+	return m.query("SHOW GRANTS FOR CURRENT_USER()", func(rows *sql.Rows) error {
+		for rows.Next() {
+			var g string
+			if err := rows.Scan(&g); err != nil {
+				return err
+			}
+			p.Grants = append(p.Grants, g)
+		}
+		return nil
+	})
+}
+
+func (m *MySQL) probe(req Requirement, query string) string {
+	var one int
+	err := m.scanRow(query, &one)
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrDryRun) {
+		return ""
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case 1142, 1143, 1044:
+			// ER_TABLEACCESS_DENIED_ERROR, ER_COLUMNACCESS_DENIED_ERROR,
+			// ER_DBACCESS_DENIED_ERROR
+			return "requires " + req.String()
+		case 1049:
+			// ER_BAD_DB_ERROR
+			_, object := req.Privilege()
+			return strings.TrimSuffix(object, ".*") + " schema not installed"
+		}
+	}
+	_, object := req.Privilege()
+	return fmt.Sprintf("%s not readable: %v", strings.TrimSuffix(object, ".*"), err)
+}
+
+func globalPrivileges(grants []string) map[string]bool {
+	privs := make(map[string]bool)
+	for _, g := range grants {
+		match := globalGrantRe.FindStringSubmatch(g)
+		if match == nil {
+			continue
+		}
+		for _, p := range strings.Split(match[1], ",") {
+			privs[strings.ToUpper(strings.TrimSpace(p))] = true
+		}
+	}
+	return privs
+}
+
+func hasAny(privs map[string]bool, names ...string) bool {
+	for _, n := range names {
+		if privs[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantStatements returns the minimal GRANT statements needed to run the
+// full check suite as user@host.
+func GrantStatements(user, host string) []string {
+	account := quoteString(user) + "@" + quoteString(host)
+	var global []string
+	var stmts []string
+	for _, req := range AllRequirements {
+		priv, object := req.Privilege()
+		if object == "*.*" {
+			global = append(global, priv)
+			continue
+		}
+		stmts = append(stmts, fmt.Sprintf("GRANT %s ON %s TO %s;", priv, object, account))
+	}
+	if len(global) > 0 {
+		stmts = append([]string{fmt.Sprintf("GRANT %s ON *.* TO %s;", strings.Join(global, ", "), account)}, stmts...)
+	}
+	return stmts
+}
+
+// quoteString returns s as a single-quoted SQL string literal.
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
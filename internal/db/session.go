@@ -0,0 +1,77 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SessionLimits bound the impact the tool can have on the server.
+type SessionLimits struct {
+	// MaxExecutionTime aborts any single statement running longer than this.
+	MaxExecutionTime time.Duration
+	// LockWaitTimeout caps both metadata lock and InnoDB row lock waits.
+	LockWaitTimeout time.Duration
+	// MaxThreadsRunning is the Threads_running value above which expensive
+	// checks are skipped. 0 disables the guard.
+	MaxThreadsRunning int
+}
+
+// Harden makes the session read-only and applies the statement and lock
+// wait limits. The server flavor decides which statement timeout variable
+// is used: max_execution_time (MySQL 5.7.8+, milliseconds) or
+// max_statement_time (MariaDB 10.1+, seconds). In dry-run mode both are
+// recorded since the flavor is unknown.
+func (m *MySQL) Harden(limits SessionLimits) error {
+	m.Limits = limits
+
+	if !m.dryRun {
+		if err := m.scanRow("SELECT VERSION()", &m.Version); err != nil {
+			return fmt.Errorf("SELECT VERSION(): %w", err)
+		}
+	}
+
+	lockWait := int(limits.LockWaitTimeout / time.Second)
+	if lockWait < 1 {
+		lockWait = 1
+	}
+	stmts := []string{
+		"SET SESSION TRANSACTION READ ONLY",
+		fmt.Sprintf("SET SESSION lock_wait_timeout = %d", lockWait),
+		fmt.Sprintf("SET SESSION innodb_lock_wait_timeout = %d", lockWait),
+	}
+	if limits.MaxExecutionTime > 0 {
+		mysqlStmt := fmt.Sprintf("SET SESSION max_execution_time = %d", limits.MaxExecutionTime.Milliseconds())
+		mariaStmt := fmt.Sprintf("SET SESSION max_statement_time = %.3f", limits.MaxExecutionTime.Seconds())
+		switch {
+		case m.dryRun:
+			stmts = append(stmts, mysqlStmt, mariaStmt)
+		case m.IsMariaDB():
+			if m.VersionAtLeast(10, 1, 1) {
+				stmts = append(stmts, mariaStmt)
+			}
+		case m.VersionAtLeast(5, 7, 8):
+			stmts = append(stmts, mysqlStmt)
+		}
+	}
+
+	for _, s := range stmts {
+		if err := m.exec(s); err != nil {
+			return fmt.Errorf("%s: %w", s, err)
+		}
+	}
+	return nil
+}
+
+// Overloaded reports whether the server is too busy for expensive checks,
+// based on Threads_running from the last LoadAll.
+func (m *MySQL) Overloaded() (string, bool) {
+	if m.Limits.MaxThreadsRunning <= 0 {
+		return "", false
+	}
+	running, err := strconv.Atoi(m.Status["Threads_running"])
+	if err != nil || running <= m.Limits.MaxThreadsRunning {
+		return "", false
+	}
+	return fmt.Sprintf("server under load (Threads_running=%d > %d)", running, m.Limits.MaxThreadsRunning), true
+}
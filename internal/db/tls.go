@@ -0,0 +1,45 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// tlsVerifyCA is the tls DSN parameter the config package uses for
+// ssl-mode=VERIFY_CA: the server certificate must chain to a trusted CA,
+// but its host name is not checked. The driver's tls=true checks both,
+// which is VERIFY_IDENTITY.
+const tlsVerifyCA = "verify-ca"
+
+func init() {
+	mysql.RegisterTLSConfig(tlsVerifyCA, &tls.Config{
+		// Host name verification is skipped; verifyChain checks the chain.
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifyChain,
+	})
+}
+
+// verifyChain verifies the server certificate against the system roots,
+// using the other certificates the server sent as intermediates.
+func verifyChain(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("tls: server sent no certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{Intermediates: x509.NewCertPool()}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,285 @@
+package host
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// Cgroup holds the effective resource limits of a process's cgroup. Limits
+// set on ancestor cgroups are taken into account; the tightest one wins.
+type Cgroup struct {
+	Version int    // 1 or 2
+	Path    string // cgroup path as listed in /proc/<pid>/cgroup
+	Dir     string // v2 only: the cgroup's directory under /sys/fs/cgroup
+
+	MemoryLimit uint64 // bytes, 0 if unlimited
+	MemoryUsage uint64 // bytes charged to the cgroup, including page cache
+	// MemoryInactiveFile is reclaimable page cache; usage minus this is the
+	// working set the OOM killer looks at.
+	MemoryInactiveFile uint64
+
+	CPUQuota float64 // cores allowed by the CFS quota, 0 if unlimited
+	CPUSet   int     // CPUs in the effective cpuset, 0 if unknown
+}
+
+// WorkingSet returns memory usage excluding reclaimable page cache.
+func (c *Cgroup) WorkingSet() uint64 {
+	if c.MemoryInactiveFile > c.MemoryUsage {
+		return 0
+	}
+	return c.MemoryUsage - c.MemoryInactiveFile
+}
+
+// CPULimit returns the number of cores the cgroup may use, or 0 if neither
+// a quota nor a cpuset restricts it.
+func (c *Cgroup) CPULimit() float64 {
+	limit := c.CPUQuota
+	if c.CPUSet > 0 && (limit == 0 || float64(c.CPUSet) < limit) {
+		limit = float64(c.CPUSet)
+	}
+	return limit
+}
+
+// ReadCgroup reads the cgroup limits of pid, or of the current process if
+// pid is 0.
+func ReadCgroup(pid int) (*Cgroup, error) {
+	proc := "self"
+	if pid > 0 {
+		proc = strconv.Itoa(pid)
+	}
+	data, err := ReadFile(filepath.Join("/proc", proc, "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+
+	v1, v2, hasV2 := parseProcCgroup(string(data))
+	if _, ok := v1["memory"]; ok {
+		return readCgroupV1(cgroupRoot, v1), nil
+	}
+	if hasV2 {
+		return readCgroupV2(cgroupRoot, v2), nil
+	}
+	return nil, fmt.Errorf("no cgroup membership found for %s", proc)
+}
+
+// parseProcCgroup splits a /proc/<pid>/cgroup file into the v1 paths keyed
+// by controller and the v2 path, if the process is in a cgroup2 hierarchy.
+func parseProcCgroup(data string) (v1 map[string]string, v2 string, hasV2 bool) {
+	v1 = make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			v2 = parts[2]
+			hasV2 = true
+			continue
+		}
+		for _, ctrl := range strings.Split(parts[1], ",") {
+			v1[ctrl] = parts[2]
+		}
+	}
+	return v1, v2, hasV2
+}
+
+// readCgroupV2 reads the limits of the cgroup at path below root, the
+// cgroup2 mount.
+func readCgroupV2(root, path string) *Cgroup {
+	cg := &Cgroup{Version: 2, Path: path}
+	dirs := cgroupDirs(root, path)
+	if len(dirs) == 0 {
+		return cg
+	}
+	leaf := dirs[0]
+	cg.Dir = leaf
+
+	for _, d := range dirs {
+		if v, ok := readLimit(filepath.Join(d, "memory.max")); ok {
+			cg.MemoryLimit = minLimit(cg.MemoryLimit, v)
+		}
+		if quota := readCPUMax(filepath.Join(d, "cpu.max")); quota > 0 &&
+			(cg.CPUQuota == 0 || quota < cg.CPUQuota) {
+			cg.CPUQuota = quota
+		}
+	}
+	cg.MemoryUsage, _ = readUint(filepath.Join(leaf, "memory.current"))
+	cg.MemoryInactiveFile = readStat(filepath.Join(leaf, "memory.stat"), "inactive_file")
+	if data, err := ReadFile(filepath.Join(leaf, "cpuset.cpus.effective")); err == nil {
+		cg.CPUSet = CountCPUList(string(data))
+	}
+	return cg
+}
+
+// readCgroupV1 reads the limits of the cgroups in paths, keyed by
+// controller, from the per-controller hierarchies mounted below root.
+func readCgroupV1(root string, paths map[string]string) *Cgroup {
+	cg := &Cgroup{Version: 1, Path: paths["memory"]}
+
+	if dirs := cgroupDirs(filepath.Join(root, "memory"), paths["memory"]); len(dirs) > 0 {
+		for _, d := range dirs {
+			if v, ok := readLimit(filepath.Join(d, "memory.limit_in_bytes")); ok {
+				cg.MemoryLimit = minLimit(cg.MemoryLimit, v)
+			}
+		}
+		cg.MemoryUsage, _ = readUint(filepath.Join(dirs[0], "memory.usage_in_bytes"))
+		cg.MemoryInactiveFile = readStat(filepath.Join(dirs[0], "memory.stat"), "total_inactive_file")
+	}
+
+	for _, mount := range []string{"cpu,cpuacct", "cpu"} {
+		dirs := cgroupDirs(filepath.Join(root, mount), paths["cpu"])
+		if len(dirs) == 0 {
+			continue
+		}
+		for _, d := range dirs {
+			quota, err1 := readInt(filepath.Join(d, "cpu.cfs_quota_us"))
+			period, err2 := readInt(filepath.Join(d, "cpu.cfs_period_us"))
+			if err1 != nil || err2 != nil || quota <= 0 || period <= 0 {
+				continue
+			}
+			if q := float64(quota) / float64(period); cg.CPUQuota == 0 || q < cg.CPUQuota {
+				cg.CPUQuota = q
+			}
+		}
+		break
+	}
+
+	if dirs := cgroupDirs(filepath.Join(root, "cpuset"), paths["cpuset"]); len(dirs) > 0 {
+		for _, name := range []string{"cpuset.effective_cpus", "cpuset.cpus"} {
+			if data, err := ReadFile(filepath.Join(dirs[0], name)); err == nil {
+				cg.CPUSet = CountCPUList(string(data))
+				break
+			}
+		}
+	}
+	return cg
+}
+
+// cgroupDirs returns the directory of path under mount followed by each of
+// its ancestors up to the mount root. If path is not visible from this
+// mount namespace (e.g. we run inside the container), the mount root alone
+// is used since that is the container's own cgroup.
+func cgroupDirs(mount, path string) []string {
+	if _, err := os.Stat(mount); err != nil {
+		return nil
+	}
+	dir := filepath.Join(mount, path)
+	if _, err := os.Stat(dir); err != nil {
+		return []string{mount}
+	}
+	var dirs []string
+	for {
+		dirs = append(dirs, dir)
+		if dir == mount || len(dir) < len(mount) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return dirs
+}
+
+// readLimit parses a memory limit file. "max" and the v1 "unlimited" value
+// (a page-aligned number close to 2^63) report ok=false.
+func readLimit(path string) (uint64, bool) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	s := strings.TrimSpace(string(data))
+	if s == "max" {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || v >= math.MaxInt64/2 {
+		return 0, false
+	}
+	return v, true
+}
+
+// readCPUMax parses cgroup v2 cpu.max ("$QUOTA $PERIOD" or "max $PERIOD")
+// into a number of cores.
+func readCPUMax(path string) float64 {
+	data, err := ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || period == 0 {
+		return 0
+	}
+	return quota / period
+}
+
+func readStat(path, key string) uint64 {
+	data, err := ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			v, _ := strconv.ParseUint(fields[1], 10, 64)
+			return v
+		}
+	}
+	return 0
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func readInt(path string) (int64, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func minLimit(cur, v uint64) uint64 {
+	if cur == 0 || v < cur {
+		return v
+	}
+	return cur
+}
+
+// CountCPUList counts the CPUs in a kernel CPU list such as "0-3,8,10-11".
+func CountCPUList(list string) int {
+	count := 0
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(lo)
+		if err != nil {
+			continue
+		}
+		if !isRange {
+			count++
+			continue
+		}
+		b, err := strconv.Atoi(hi)
+		if err != nil || b < a {
+			continue
+		}
+		count += b - a + 1
+	}
+	return count
+}
@@ -0,0 +1,201 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates the given files, keyed by slash-separated path, below
+// dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseProcCgroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantV1    map[string]string
+		wantV2    string
+		wantHasV2 bool
+	}{
+		{"v2 only", "0::/system.slice/mysql.service\n", map[string]string{}, "/system.slice/mysql.service", true},
+		{"v2 root", "0::/\n", map[string]string{}, "/", true},
+		{
+			"v1",
+			"12:memory:/docker/abc\n11:cpu,cpuacct:/docker/abc\n3:cpuset:/docker/abc\n1:name=systemd:/docker/abc\n",
+			map[string]string{
+				"memory":       "/docker/abc",
+				"cpu":          "/docker/abc",
+				"cpuacct":      "/docker/abc",
+				"cpuset":       "/docker/abc",
+				"name=systemd": "/docker/abc",
+			},
+			"", false,
+		},
+		{
+			"hybrid",
+			"4:memory:/user.slice\n0::/user.slice/session-1.scope\n",
+			map[string]string{"memory": "/user.slice"},
+			"/user.slice/session-1.scope", true,
+		},
+		{"malformed lines", "garbage\n\n", map[string]string{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v1, v2, hasV2 := parseProcCgroup(tt.in)
+			if !reflect.DeepEqual(v1, tt.wantV1) || v2 != tt.wantV2 || hasV2 != tt.wantHasV2 {
+				t.Errorf("parseProcCgroup(%q) = %v, %q, %v, want %v, %q, %v",
+					tt.in, v1, v2, hasV2, tt.wantV1, tt.wantV2, tt.wantHasV2)
+			}
+		})
+	}
+}
+
+func TestReadCgroupV2(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		files map[string]string
+		want  Cgroup
+	}{
+		{
+			"unlimited",
+			"/app",
+			map[string]string{
+				"app/memory.max":     "max\n",
+				"app/cpu.max":        "max 100000\n",
+				"app/memory.current": "1048576\n",
+			},
+			Cgroup{MemoryUsage: 1 << 20},
+		},
+		{
+			"limits on the leaf",
+			"/app",
+			map[string]string{
+				"app/memory.max":            "2147483648\n",
+				"app/cpu.max":               "150000 100000\n",
+				"app/memory.current":        "1073741824\n",
+				"app/memory.stat":           "anon 100\ninactive_file 4096\nactive_file 8192\n",
+				"app/cpuset.cpus.effective": "0-3\n",
+			},
+			Cgroup{MemoryLimit: 2 << 30, MemoryUsage: 1 << 30, MemoryInactiveFile: 4096, CPUQuota: 1.5, CPUSet: 4},
+		},
+		{
+			"tighter limits on an ancestor",
+			"/parent/app",
+			map[string]string{
+				"parent/memory.max":     "1073741824\n",
+				"parent/cpu.max":        "50000 100000\n",
+				"parent/app/memory.max": "2147483648\n",
+				"parent/app/cpu.max":    "200000 100000\n",
+			},
+			Cgroup{MemoryLimit: 1 << 30, CPUQuota: 0.5},
+		},
+		{
+			"path not visible uses the mount root",
+			"/elsewhere",
+			map[string]string{"memory.max": "536870912\n"},
+			Cgroup{MemoryLimit: 512 << 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+			got := readCgroupV2(root, tt.path)
+			got.Dir = ""
+			want := tt.want
+			want.Version = 2
+			want.Path = tt.path
+			if *got != want {
+				t.Errorf("readCgroupV2(%q) = %+v, want %+v", tt.path, *got, want)
+			}
+		})
+	}
+}
+
+func TestReadCgroupV1(t *testing.T) {
+	paths := map[string]string{"memory": "/docker/abc", "cpu": "/docker/abc", "cpuset": "/docker/abc"}
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  Cgroup
+	}{
+		{
+			"unlimited",
+			map[string]string{
+				"memory/docker/abc/memory.limit_in_bytes":  "9223372036854771712\n",
+				"memory/docker/abc/memory.usage_in_bytes":  "1048576\n",
+				"cpu,cpuacct/docker/abc/cpu.cfs_quota_us":  "-1\n",
+				"cpu,cpuacct/docker/abc/cpu.cfs_period_us": "100000\n",
+				"cpuset/docker/abc/cpuset.effective_cpus":  "0-7\n",
+				"cpuset/docker/abc/cpuset.cpus":            "0-15\n",
+				"memory/docker/abc/memory.stat":            "total_inactive_file 4096\n",
+				"cpu,cpuacct/docker/cpu.cfs_quota_us":      "-1\n",
+				"cpu,cpuacct/docker/cpu.cfs_period_us":     "100000\n",
+				"memory/docker/memory.limit_in_bytes":      "9223372036854771712\n",
+				"memory/memory.limit_in_bytes":             "9223372036854771712\n",
+				"cpu,cpuacct/cpu.cfs_quota_us":             "-1\n",
+				"cpu,cpuacct/cpu.cfs_period_us":            "100000\n",
+				"cpuset/docker/cpuset.cpus":                "0-15\n",
+			},
+			Cgroup{MemoryUsage: 1 << 20, MemoryInactiveFile: 4096, CPUSet: 8},
+		},
+		{
+			"limited, cpu mounted without cpuacct",
+			map[string]string{
+				"memory/docker/abc/memory.limit_in_bytes": "1073741824\n",
+				"memory/docker/memory.limit_in_bytes":     "536870912\n",
+				"cpu/docker/abc/cpu.cfs_quota_us":         "200000\n",
+				"cpu/docker/abc/cpu.cfs_period_us":        "100000\n",
+				"cpuset/docker/abc/cpuset.cpus":           "0,2\n",
+			},
+			Cgroup{MemoryLimit: 512 << 20, CPUQuota: 2, CPUSet: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+			got := readCgroupV1(root, paths)
+			want := tt.want
+			want.Version = 1
+			want.Path = paths["memory"]
+			if *got != want {
+				t.Errorf("readCgroupV1() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestCountCPUList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"0\n", 1},
+		{"0-3", 4},
+		{"0-3,8,10-11\n", 7},
+		{"0,2,4,6", 4},
+		{"3-1", 0},
+		{"x,1", 1},
+	}
+	for _, tt := range tests {
+		if got := CountCPUList(tt.in); got != tt.want {
+			t.Errorf("CountCPUList(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
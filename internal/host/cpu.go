@@ -0,0 +1,54 @@
+package host
+
+import (
+	"encoding/binary"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+const atClkTck = 17 // AT_CLKTCK in <elf.h>
+
+var clockTicks = sync.OnceValue(func() int {
+	data, err := ReadFile("/proc/self/auxv")
+	if err != nil {
+		return 100
+	}
+	word := int(unsafe.Sizeof(uintptr(0)))
+	for i := 0; i+2*word <= len(data); i += 2 * word {
+		var key, val uint64
+		if word == 8 {
+			key = binary.NativeEndian.Uint64(data[i:])
+			val = binary.NativeEndian.Uint64(data[i+word:])
+		} else {
+			key = uint64(binary.NativeEndian.Uint32(data[i:]))
+			val = uint64(binary.NativeEndian.Uint32(data[i+word:]))
+		}
+		if key == 0 {
+			break
+		}
+		if key == atClkTck && val > 0 {
+			return int(val)
+		}
+	}
+	return 100
+})
+
+// ClockTicks returns the kernel's USER_HZ, the unit of the CPU times in
+// /proc/<pid>/stat and /proc/stat, as passed to the process in its auxiliary
+// vector. It falls back to 100, the value on all mainstream architectures.
+func ClockTicks() int {
+	return clockTicks()
+}
+
+// OnlineCPUs returns the number of online CPUs from sysfs, falling back to
+// the Go runtime's view.
+func OnlineCPUs() int {
+	if data, err := ReadFile("/sys/devices/system/cpu/online"); err == nil {
+		if n := CountCPUList(strings.TrimSpace(string(data))); n > 0 {
+			return n
+		}
+	}
+	return runtime.NumCPU()
+}
@@ -0,0 +1,30 @@
+package host
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// CPUAffinity returns the number of CPUs pid may run on according to
+// sched_getaffinity(2). The mask only contains online CPUs.
+func CPUAffinity(pid int) (int, error) {
+	for size := 128; size <= 1<<16; size *= 2 {
+		mask := make([]byte, size)
+		n, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY,
+			uintptr(pid), uintptr(len(mask)), uintptr(unsafe.Pointer(&mask[0])))
+		if errno == syscall.EINVAL {
+			continue
+		}
+		if errno != 0 {
+			return 0, errno
+		}
+		count := 0
+		for _, b := range mask[:n] {
+			for ; b != 0; b &= b - 1 {
+				count++
+			}
+		}
+		return count, nil
+	}
+	return 0, syscall.EINVAL
+}
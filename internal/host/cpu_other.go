@@ -0,0 +1,9 @@
+//go:build !linux
+
+package host
+
+// CPUAffinity falls back to the number of online CPUs where
+// sched_getaffinity(2) is not available.
+func CPUAffinity(pid int) (int, error) {
+	return OnlineCPUs(), nil
+}
@@ -0,0 +1,221 @@
+// Package host detects facts about the machine the tool runs on: the Linux
+// distribution, kernel and whether it runs in a container or VM. Checks use
+// these facts to decide whether host-level measurements are meaningful.
+package host
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+type Info struct {
+	OSID        string // os-release ID, e.g. "debian", "ubuntu"
+	OSVersionID string // os-release VERSION_ID, e.g. "12"
+	OSName      string // os-release PRETTY_NAME
+	Kernel      string // kernel release, e.g. "6.1.0-18-amd64"
+
+	// Container is the container runtime ("docker", "podman", "lxc",
+	// "kubernetes", "gvisor", ...) or empty on a bare host or VM.
+	Container string
+	// Virtualization is the hypervisor ("kvm", "vmware", "xen", "hyperv",
+	// "amazon", ...) or empty on bare metal or when it cannot be told.
+	Virtualization string
+
+	// ServerRemote is why the MySQL server is not on this host (a remote
+	// address or a managed service), or empty when it is. It is set by the
+	// caller once connected, since Detect cannot know.
+	ServerRemote string
+
+	// HostChecksDisabled is set when host checks were turned off with
+	// -host-checks=off for a server that may well be local.
+	HostChecksDisabled bool
+}
+
+// Detect gathers host facts. Missing files are not errors; the matching
+// fields are left empty.
+func Detect() *Info {
+	i := &Info{}
+	if data, err := ReadFile("/etc/os-release"); err == nil {
+		i.parseOSRelease(string(data))
+	} else if data, err := ReadFile("/usr/lib/os-release"); err == nil {
+		i.parseOSRelease(string(data))
+	}
+	if data, err := ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		i.Kernel = strings.TrimSpace(string(data))
+	}
+	i.Container = detectContainer()
+	i.Virtualization = detectVirtualization()
+	return i
+}
+
+// GVisor reports whether the tool runs inside a gVisor sandbox, where /proc
+// is emulated and does not reflect the real host.
+func (i *Info) GVisor() bool {
+	return i.Container == "gvisor"
+}
+
+// ProcUnreliable returns a reason when /proc-based host measurements do not
+// describe the MySQL server: /proc is emulated, or the server runs on
+// another machine.
+func (i *Info) ProcUnreliable() (string, bool) {
+	if i.GVisor() {
+		return "/proc is emulated inside gVisor", true
+	}
+	return i.HostChecksSkipped()
+}
+
+// HostChecksSkipped returns why checks of the local /proc and /sys do not
+// describe the server: it is remote, or host checks were disabled.
+func (i *Info) HostChecksSkipped() (string, bool) {
+	switch {
+	case i.ServerRemote != "":
+		return i.ServerRemote, true
+	case i.HostChecksDisabled:
+		return "host checks disabled with -host-checks=off", true
+	}
+	return "", false
+}
+
+// Platform describes where the tool runs, e.g. "docker on kvm".
+func (i *Info) Platform() string {
+	switch {
+	case i.Container != "" && i.Virtualization != "":
+		return i.Container + " on " + i.Virtualization
+	case i.Container != "":
+		return i.Container
+	case i.Virtualization != "":
+		return i.Virtualization
+	default:
+		return "bare metal"
+	}
+}
+
+// OS returns a human-readable distribution name.
+func (i *Info) OS() string {
+	switch {
+	case i.OSName != "":
+		return i.OSName
+	case i.OSID != "":
+		return strings.TrimSpace(i.OSID + " " + i.OSVersionID)
+	default:
+		return "unknown"
+	}
+}
+
+func (i *Info) parseOSRelease(data string) {
+	for _, line := range strings.Split(data, "\n") {
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		val = strings.Trim(val, `"'`)
+		switch key {
+		case "ID":
+			i.OSID = val
+		case "VERSION_ID":
+			i.OSVersionID = val
+		case "PRETTY_NAME":
+			i.OSName = val
+		}
+	}
+}
+
+func detectContainer() string {
+	// gVisor reports a fixed fake kernel build.
+	if data, err := ReadFile("/proc/version"); err == nil &&
+		strings.Contains(string(data), "Sun Jan 10 15:06:54 PST 2016") {
+		return "gvisor"
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	if data, err := ReadFile("/proc/1/environ"); err == nil {
+		for _, kv := range strings.Split(string(data), "\x00") {
+			if v, ok := strings.CutPrefix(kv, "container="); ok && v != "" {
+				return v
+			}
+		}
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if data, err := ReadFile("/proc/1/cgroup"); err == nil {
+		return cgroupContainer(string(data))
+	}
+	return ""
+}
+
+// cgroupContainer recognises a container runtime from the cgroup paths of
+// PID 1.
+func cgroupContainer(cgroups string) string {
+	switch {
+	case strings.Contains(cgroups, "kubepods"):
+		return "kubernetes"
+	case strings.Contains(cgroups, "docker"):
+		return "docker"
+	case strings.Contains(cgroups, "libpod"):
+		return "podman"
+	case strings.Contains(cgroups, "/lxc"):
+		return "lxc"
+	}
+	return ""
+}
+
+func detectVirtualization() string {
+	if v := dmiVirtualization(dmi("sys_vendor"), dmi("product_name")); v != "" {
+		return v
+	}
+	if data, err := ReadFile("/sys/hypervisor/type"); err == nil {
+		if t := strings.TrimSpace(string(data)); t != "" {
+			return t
+		}
+	}
+	if data, err := ReadFile("/proc/cpuinfo"); err == nil && strings.Contains(string(data), " hypervisor") {
+		return "vm"
+	}
+	return ""
+}
+
+// dmiVirtualization recognises a hypervisor from the DMI system vendor and
+// product name.
+func dmiVirtualization(vendor, product string) string {
+	switch {
+	case strings.Contains(product, "KVM") || strings.Contains(vendor, "QEMU"):
+		return "kvm"
+	case strings.Contains(vendor, "VMware"):
+		return "vmware"
+	case strings.Contains(vendor, "Microsoft") && strings.Contains(product, "Virtual"):
+		return "hyperv"
+	case strings.Contains(vendor, "Xen"):
+		return "xen"
+	case strings.Contains(vendor, "Amazon"):
+		return "amazon"
+	case strings.Contains(vendor, "Google"):
+		return "google"
+	case strings.Contains(product, "VirtualBox"):
+		return "virtualbox"
+	}
+	return ""
+}
+
+func dmi(name string) string {
+	data, err := ReadFile("/sys/class/dmi/id/" + name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ReadFile reads a host file such as one under /proc or /sys, logging the
+// read at debug level so that -debug shows every host access.
+func ReadFile(path string) ([]byte, error) {
+	start := time.Now()
+	data, err := os.ReadFile(path)
+	slog.Debug("read file", "path", path, "bytes", len(data), "duration", time.Since(start), "err", err)
+	return data, err
+}
@@ -0,0 +1,119 @@
+package host
+
+import "testing"
+
+func TestParseOSRelease(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Info
+	}{
+		{
+			"debian",
+			"PRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nNAME=\"Debian GNU/Linux\"\n" +
+				"VERSION_ID=\"12\"\nID=debian\n",
+			Info{OSID: "debian", OSVersionID: "12", OSName: "Debian GNU/Linux 12 (bookworm)"},
+		},
+		{
+			"single quotes",
+			"ID='almalinux'\nVERSION_ID='9.3'\n",
+			Info{OSID: "almalinux", OSVersionID: "9.3"},
+		},
+		{
+			"rolling release without VERSION_ID",
+			"NAME=\"Arch Linux\"\nPRETTY_NAME=\"Arch Linux\"\nID=arch\nBUILD_ID=rolling\n",
+			Info{OSID: "arch", OSName: "Arch Linux"},
+		},
+		{"empty", "", Info{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Info
+			got.parseOSRelease(tt.in)
+			if got != tt.want {
+				t.Errorf("parseOSRelease() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOS(t *testing.T) {
+	tests := []struct {
+		info Info
+		want string
+	}{
+		{Info{OSID: "debian", OSVersionID: "12", OSName: "Debian GNU/Linux 12 (bookworm)"}, "Debian GNU/Linux 12 (bookworm)"},
+		{Info{OSID: "debian", OSVersionID: "12"}, "debian 12"},
+		{Info{OSID: "arch"}, "arch"},
+		{Info{}, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.OS(); got != tt.want {
+			t.Errorf("%+v.OS() = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestPlatform(t *testing.T) {
+	tests := []struct {
+		container, virt string
+		want            string
+	}{
+		{"docker", "kvm", "docker on kvm"},
+		{"lxc", "", "lxc"},
+		{"", "vmware", "vmware"},
+		{"", "", "bare metal"},
+	}
+	for _, tt := range tests {
+		i := Info{Container: tt.container, Virtualization: tt.virt}
+		if got := i.Platform(); got != tt.want {
+			t.Errorf("Platform() with container %q, virtualization %q = %q, want %q",
+				tt.container, tt.virt, got, tt.want)
+		}
+	}
+}
+
+func TestCgroupContainer(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"kubernetes", "0::/kubepods/burstable/pod1234/abcd\n", "kubernetes"},
+		{"docker", "12:memory:/docker/3f2a\n0::/system.slice/docker-3f2a.scope\n", "docker"},
+		{"podman", "0::/machine.slice/libpod-3f2a.scope\n", "podman"},
+		{"lxc", "0::/lxc.payload.web/init.scope\n", "lxc"},
+		{"host", "0::/init.scope\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cgroupContainer(tt.in); got != tt.want {
+				t.Errorf("cgroupContainer(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDMIVirtualization(t *testing.T) {
+	tests := []struct {
+		vendor, product string
+		want            string
+	}{
+		{"QEMU", "Standard PC (Q35 + ICH9, 2009)", "kvm"},
+		{"Red Hat", "KVM", "kvm"},
+		{"VMware, Inc.", "VMware Virtual Platform", "vmware"},
+		{"Microsoft Corporation", "Virtual Machine", "hyperv"},
+		{"Microsoft Corporation", "Surface Laptop", ""},
+		{"Xen", "HVM domU", "xen"},
+		{"Amazon EC2", "m5.large", "amazon"},
+		{"Google", "Google Compute Engine", "google"},
+		{"innotek GmbH", "VirtualBox", "virtualbox"},
+		{"Dell Inc.", "PowerEdge R640", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := dmiVirtualization(tt.vendor, tt.product); got != tt.want {
+			t.Errorf("dmiVirtualization(%q, %q) = %q, want %q", tt.vendor, tt.product, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package host
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Pressure is a pressure stall information (PSI) file: the share of wall
+// time in which some, or all, runnable tasks were stalled on a resource.
+type Pressure struct {
+	Some    PressureAvg
+	Full    PressureAvg
+	HasFull bool // cpu has no meaningful "full" line before 5.13
+}
+
+// PressureAvg holds the running averages in percent.
+type PressureAvg struct {
+	Avg10, Avg60, Avg300 float64
+}
+
+// ReadPressure reads the system-wide PSI of resource ("cpu", "memory" or
+// "io") from /proc/pressure.
+func ReadPressure(resource string) (*Pressure, error) {
+	return readPressure(filepath.Join("/proc/pressure", resource))
+}
+
+// Pressure reads the PSI of resource for the cgroup. Only cgroup v2 tracks
+// pressure per cgroup.
+func (c *Cgroup) Pressure(resource string) (*Pressure, error) {
+	if c.Version != 2 || c.Dir == "" {
+		return nil, fmt.Errorf("cgroup v%d has no pressure files", c.Version)
+	}
+	return readPressure(filepath.Join(c.Dir, resource+".pressure"))
+}
+
+func readPressure(path string) (*Pressure, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pressure{}
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		var avg PressureAvg
+		for _, f := range fields[1:] {
+			key, val, ok := strings.Cut(f, "=")
+			if !ok {
+				continue
+			}
+			v, _ := strconv.ParseFloat(val, 64)
+			switch key {
+			case "avg10":
+				avg.Avg10 = v
+			case "avg60":
+				avg.Avg60 = v
+			case "avg300":
+				avg.Avg300 = v
+			}
+		}
+		switch fields[0] {
+		case "some":
+			p.Some = avg
+			found = true
+		case "full":
+			p.Full = avg
+			p.HasFull = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no PSI data in %s", path)
+	}
+	return p, nil
+}
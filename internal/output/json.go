@@ -0,0 +1,115 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/hpowernl/MySQL_check/internal/checks"
+)
+
+// JSONRenderer writes the report as a single JSON document on stdout for
+// consumption by monitoring systems.
+type JSONRenderer struct{}
+
+type jsonReport struct {
+	Host          string         `json:"host"`
+	CnfPath       string         `json:"cnf,omitempty"`
+	MySQLVersion  string         `json:"mysql_version,omitempty"`
+	OS            *jsonOS        `json:"os,omitempty"`
+	ServerRemote  string         `json:"server_remote,omitempty"`
+	HostChecksOff bool           `json:"host_checks_disabled,omitempty"`
+	Overall       string         `json:"overall"`
+	ExitCode      int            `json:"exit_code"`
+	Error         *ErrorInfo     `json:"error,omitempty"`
+	Categories    []jsonCategory `json:"categories,omitempty"`
+}
+
+type jsonOS struct {
+	ID             string `json:"id"`
+	VersionID      string `json:"version_id"`
+	Name           string `json:"name"`
+	Kernel         string `json:"kernel"`
+	Container      string `json:"container,omitempty"`
+	Virtualization string `json:"virtualization,omitempty"`
+}
+
+type jsonCategory struct {
+	Name   string      `json:"name"`
+	Level  string      `json:"level"`
+	Checks []jsonCheck `json:"checks"`
+}
+
+type jsonCheck struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Level       string `json:"level"`
+	Threshold   string `json:"threshold"`
+	Description string `json:"description"`
+	Detail      string `json:"detail,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// ErrorInfo describes a failure that prevented the checks from running.
+type ErrorInfo struct {
+	Kind     string `json:"kind"`
+	Code     uint16 `json:"code,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (r *JSONRenderer) Render(categories []checks.Category, h Header, exitCode int) {
+	rep := newJSONReport(h, exitCode)
+	rep.Overall = checks.OverallLevel(categories).String()
+	for _, cat := range categories {
+		jc := jsonCategory{Name: cat.Name, Level: cat.WorstLevel().String()}
+		for _, ch := range cat.Checks {
+			jc.Checks = append(jc.Checks, jsonCheck{
+				Name:        ch.Name,
+				Value:       ch.Value,
+				Level:       ch.Level.String(),
+				Threshold:   ch.Threshold,
+				Description: ch.Description,
+				Detail:      ch.Detail,
+				Reason:      ch.Reason,
+			})
+		}
+		rep.Categories = append(rep.Categories, jc)
+	}
+	r.write(rep)
+}
+
+// RenderError reports a fatal failure in place of the check results.
+func (r *JSONRenderer) RenderError(info ErrorInfo, h Header, exitCode int) {
+	rep := newJSONReport(h, exitCode)
+	rep.Overall = "UNKNOWN"
+	rep.Error = &info
+	r.write(rep)
+}
+
+func newJSONReport(h Header, exitCode int) jsonReport {
+	rep := jsonReport{
+		Host:         h.Hostname,
+		CnfPath:      h.CnfPath,
+		MySQLVersion: h.MySQLVersion,
+		ExitCode:     exitCode,
+	}
+	if h.Host != nil {
+		rep.ServerRemote = h.Host.ServerRemote
+		rep.HostChecksOff = h.Host.HostChecksDisabled
+		rep.OS = &jsonOS{
+			ID:             h.Host.OSID,
+			VersionID:      h.Host.OSVersionID,
+			Name:           h.Host.OS(),
+			Kernel:         h.Host.Kernel,
+			Container:      h.Host.Container,
+			Virtualization: h.Host.Virtualization,
+		}
+	}
+	return rep
+}
+
+func (r *JSONRenderer) write(rep jsonReport) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(rep)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/hpowernl/MySQL_check/internal/checks"
+	"github.com/hpowernl/MySQL_check/internal/host"
 )
 
 const (
@@ -19,6 +20,14 @@ const (
 	colorWhite  = "\033[97m"
 )
 
+// Header is the context printed above the check results.
+type Header struct {
+	Hostname     string
+	MySQLVersion string
+	CnfPath      string
+	Host         *host.Info
+}
+
 type Renderer struct {
 	NoColor bool
 }
@@ -48,7 +57,7 @@ func (r *Renderer) levelTag(l checks.Level) string {
 	return r.c(r.levelColor(l), tag)
 }
 
-func (r *Renderer) Render(categories []checks.Category, mysqlVersion, hostname, cnfPath string) {
+func (r *Renderer) Render(categories []checks.Category, h Header) {
 	w := os.Stdout
 	lineW := 80
 
@@ -56,9 +65,18 @@ func (r *Renderer) Render(categories []checks.Category, mysqlVersion, hostname,
 	fmt.Fprintln(w)
 	fmt.Fprintln(w, r.c(colorCyan, border))
 	fmt.Fprintf(w, "  %s%s", r.c(colorBold, "MySQL Health Checks"),
-		r.pad("MySQL "+mysqlVersion, lineW-21))
+		r.pad("MySQL "+h.MySQLVersion, lineW-21))
 	fmt.Fprintln(w)
-	fmt.Fprintf(w, "  Host: %s | CNF: %s\n", hostname, cnfPath)
+	fmt.Fprintf(w, "  Host: %s | CNF: %s\n", h.Hostname, h.CnfPath)
+	if h.Host != nil {
+		fmt.Fprintf(w, "  OS: %s | Kernel: %s | Platform: %s\n", h.Host.OS(), h.Host.Kernel, h.Host.Platform())
+		switch {
+		case h.Host.ServerRemote != "":
+			fmt.Fprintf(w, "  %s\n", r.c(colorYellow, "Remote server: "+h.Host.ServerRemote+"; host checks skipped"))
+		case h.Host.HostChecksDisabled:
+			fmt.Fprintf(w, "  %s\n", r.c(colorYellow, "Host checks disabled with -host-checks=off"))
+		}
+	}
 	fmt.Fprintln(w, r.c(colorCyan, border))
 
 	for _, cat := range categories {
@@ -90,6 +108,9 @@ func (r *Renderer) Render(categories []checks.Category, mysqlVersion, hostname,
 				reason := fmt.Sprintf(">> Threshold: %s", ch.Threshold)
 				fmt.Fprintf(w, "          %s\n", r.c(r.levelColor(ch.Level), reason))
 			}
+			if ch.Level == checks.LevelSkip && ch.Reason != "" {
+				fmt.Fprintf(w, "          %s\n", r.c(colorGray, ">> Skipped: "+ch.Reason))
+			}
 
 			descLines := wrapText(ch.Description, lineW-10)
 			for _, dl := range descLines {
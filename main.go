@@ -1,115 +1,287 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/hpowernl/MySQL_check/internal/checks"
 	"github.com/hpowernl/MySQL_check/internal/config"
 	"github.com/hpowernl/MySQL_check/internal/db"
+	"github.com/hpowernl/MySQL_check/internal/host"
 	"github.com/hpowernl/MySQL_check/internal/output"
 )
 
 // Version is set at build time via ldflags (e.g. -ldflags "-X main.Version=v1.0.0")
 var Version = "dev"
 
+// Exit codes. 0-2 follow the Nagios convention for check results; the
+// remaining codes identify why the checks could not run at all.
+const (
+	exitOK          = 0
+	exitWarn        = 1
+	exitCrit        = 2
+	exitConfig      = 3
+	exitFailed      = 4
+	exitAuth        = 5
+	exitNetwork     = 6
+	exitSocket      = 7
+	exitTooManyConn = 8
+	exitTLS         = 9
+	exitTimeout     = 10
+	exitHostBlocked = 11
+)
+
 func main() {
-	cnfPath := flag.String("cnf", "/data/web/.my.cnf", "Path to .my.cnf credentials file")
-	sampleSeconds := flag.Int("sample-seconds", 3, "CPU sample duration in seconds")
+	if len(os.Args) > 1 && os.Args[1] == "grants" {
+		runGrants(os.Args[2:])
+		return
+	}
+
+	cnfPath := flag.String("cnf", "", "Path to .my.cnf credentials file (default: search "+
+		"$MYSQL_HOME/my.cnf, ~/.my.cnf, /etc/mysql/debian.cnf, /etc/my.cnf, /etc/mysql/my.cnf, "+
+		config.PlatformCnfPath+")")
+	sampleSeconds := flag.Int("sample-seconds", 3, "Sample window for rate-based host checks, in seconds")
+	historyPath := flag.String("history", "", "File keeping filesystem usage between runs for the disk-full forecast")
+	hostChecks := flag.String("host-checks", "auto", "Run checks that read the local /proc and /sys: auto (only when the server is on this host), on, off")
 	noColor := flag.Bool("no-color", false, "Disable ANSI color output")
+	jsonOut := flag.Bool("json", false, "Write the report as JSON")
+	retries := flag.Int("retries", 0, "Retry a failed connection this many times (network, socket, timeout, too many connections)")
+	retryDelay := flag.Duration("retry-delay", time.Second, "Delay before the first retry, doubled on each subsequent retry")
+	maxExecTime := flag.Duration("max-execution-time", 5*time.Second, "Abort any statement running longer than this")
+	lockWait := flag.Duration("lock-wait-timeout", 2*time.Second, "Session lock_wait_timeout and innodb_lock_wait_timeout")
+	maxThreadsRunning := flag.Int("max-threads-running", 50, "Skip expensive checks when Threads_running exceeds this (0 disables)")
+	dryRun := flag.Bool("dry-run", false, "List every SQL statement that would be executed, without connecting")
+	debug := flag.Bool("debug", false, "Log executed SQL, host file reads and check timings")
+	debugFile := flag.String("debug-file", "", "Write debug log to this file instead of stderr")
 	showVersion := flag.Bool("version", false, "Show version and exit")
 	flag.Parse()
 
+	switch *hostChecks {
+	case "auto", "on", "off":
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: -host-checks must be auto, on or off, not %q\n", *hostChecks)
+		os.Exit(exitConfig)
+	}
+
+	if *debug {
+		var w io.Writer = os.Stderr
+		if *debugFile != "" {
+			f, err := os.OpenFile(*debugFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: cannot open debug file: %v\n", err)
+				os.Exit(exitConfig)
+			}
+			defer f.Close()
+			w = f
+		}
+		slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	}
+
+	limits := db.SessionLimits{
+		MaxExecutionTime:  *maxExecTime,
+		LockWaitTimeout:   *lockWait,
+		MaxThreadsRunning: *maxThreadsRunning,
+	}
+
 	if *showVersion {
 		fmt.Printf("mysql-health-check %s\n", Version)
 		os.Exit(0)
 	}
 
-	if !checkDebian12() {
-		fmt.Fprintln(os.Stderr, "WARNING: This tool is designed for Debian 12. Detected a different OS.")
-		fmt.Fprintln(os.Stderr, "         Results may be inaccurate. Continuing anyway...")
-		fmt.Fprintln(os.Stderr)
+	if *dryRun {
+		runDryRun(limits)
+		return
+	}
+
+	hostInfo := host.Detect()
+
+	var cfg *config.MySQLConfig
+	var err error
+	usedCnf := *cnfPath
+	if usedCnf != "" {
+		cfg, err = config.ParseMyCnf(usedCnf)
+	} else {
+		cfg, usedCnf, err = config.DiscoverMyCnf()
+	}
+
+	hostname, _ := os.Hostname()
+	header := output.Header{Hostname: hostname, CnfPath: usedCnf, Host: hostInfo}
+
+	fail := func(code int, kind string, err error) {
+		if *jsonOut {
+			info := output.ErrorInfo{Kind: kind, Message: err.Error()}
+			var ce *db.ConnectError
+			if errors.As(err, &ce) {
+				info.Code = ce.Code
+				info.Attempts = ce.Attempts
+			}
+			(&output.JSONRenderer{}).RenderError(info, header, code)
+		} else {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		}
+		os.Exit(code)
 	}
 
-	cfg, err := config.ParseMyCnf(*cnfPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		os.Exit(2)
+		fail(exitConfig, "config", err)
 	}
 
-	m, err := db.Connect(cfg)
+	m, err := db.Connect(cfg, *retries, *retryDelay)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		os.Exit(2)
+		var ce *db.ConnectError
+		if errors.As(err, &ce) {
+			fail(connectExitCode(ce.Kind), ce.Kind.String(), err)
+		}
+		fail(exitFailed, "unknown", err)
 	}
 	defer m.Close()
 
+	if err := m.Harden(limits); err != nil {
+		fail(exitFailed, "session", fmt.Errorf("failed to apply read-only session limits: %w", err))
+	}
 	if err := m.LoadAll(); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: Failed to load MySQL data: %v\n", err)
-		os.Exit(2)
+		fail(exitFailed, "query", fmt.Errorf("failed to load MySQL data: %w", err))
+	}
+	if err := m.Preflight(); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: privilege preflight incomplete: %v\n", err)
+	}
+
+	switch *hostChecks {
+	case "auto":
+		hostInfo.ServerRemote = checks.RemoteReason(m, cfg)
+	case "off":
+		hostInfo.HostChecksDisabled = true
+	}
+
+	categories := runChecks(m, hostInfo, checks.SystemOptions{
+		SampleSeconds: *sampleSeconds,
+		HistoryPath:   *historyPath,
+	})
+	// After a statement timeout the connection is gone and every later
+	// check was skipped; report the timeout instead of such a report.
+	if err := m.Err(); err != nil {
+		fail(exitFailed, "statement_timeout", err)
+	}
+	header.MySQLVersion = m.Version
+
+	var code int
+	switch checks.OverallLevel(categories) {
+	case checks.LevelOK:
+		code = exitOK
+	case checks.LevelCrit:
+		code = exitCrit
+	default:
+		code = exitWarn
+	}
+
+	if *jsonOut {
+		(&output.JSONRenderer{}).Render(categories, header, code)
+	} else {
+		renderer := &output.Renderer{NoColor: *noColor}
+		renderer.Render(categories, header)
 	}
+	os.Exit(code)
+}
 
+func runChecks(m *db.MySQL, hostInfo *host.Info, sysOpts checks.SystemOptions) []checks.Category {
+	env := checks.NewEnv(m, hostInfo, sysOpts.SampleSeconds)
 	categories := []checks.Category{
 		{
 			Name:   "System",
-			Checks: checks.RunSystemChecks(m, *sampleSeconds),
+			Checks: checks.RunSystemChecks(m, hostInfo, env, sysOpts),
+		},
+		{
+			Name:   "Operating System",
+			Checks: checks.RunOSChecks(m, hostInfo, env),
 		},
 		{
+			Name:   "Network",
+			Checks: checks.RunNetworkChecks(m, hostInfo, env),
+		},
+		{
+			Name:   "Replication",
+			Checks: checks.RunReplicationChecks(m, env),
+		},
+	}
+	if galera := checks.RunGaleraChecks(m); len(galera) > 0 {
+		categories = append(categories, checks.Category{Name: "Galera", Checks: galera})
+	}
+	return append(categories,
+		checks.Category{
+			Name:   "Binary Log",
+			Checks: checks.RunBinlogChecks(m, hostInfo),
+		},
+		checks.Category{
+			Name:   "Durability",
+			Checks: checks.RunDurabilityChecks(m, env),
+		},
+		checks.Category{
 			Name:   "MyISAM / InnoDB",
 			Checks: checks.RunEngineChecks(m),
 		},
-		{
+		checks.Category{
 			Name:   "Memory",
 			Checks: checks.RunCacheChecks(m),
 		},
-		{
+		checks.Category{
 			Name:   "Queries / Logs",
-			Checks: checks.RunQueryChecks(m),
+			Checks: checks.RunQueryChecks(m, hostInfo, env),
 		},
-	}
+	)
+}
 
-	hostname, _ := os.Hostname()
+// runDryRun runs the suite against a recorder instead of a server and
+// prints the statements it would have issued. Checks take every branch that
+// depends on server state, so the list covers replicas, Group Replication
+// members and remote servers as well. The host is neither read nor sampled:
+// host checks are run as with -host-checks=off, so only SQL is recorded.
+func runDryRun(limits db.SessionLimits) {
+	m := db.NewDryRun()
+	m.Harden(limits)
+	m.LoadAll()
+	m.Preflight()
+	runChecks(m, &host.Info{HostChecksDisabled: true}, checks.SystemOptions{})
 
-	renderer := &output.Renderer{NoColor: *noColor}
-	renderer.Render(categories, m.Version, hostname, *cnfPath)
+	for _, stmt := range m.Statements() {
+		fmt.Printf("%s;\n", stmt)
+	}
+}
 
-	overall := checks.OverallLevel(categories)
-	switch overall {
-	case checks.LevelOK:
-		os.Exit(0)
-	case checks.LevelWarn:
-		os.Exit(1)
-	case checks.LevelCrit:
-		os.Exit(2)
-	default:
-		os.Exit(1)
+// runGrants prints the GRANT statements a monitoring account needs for the
+// full check suite.
+func runGrants(args []string) {
+	fs := flag.NewFlagSet("grants", flag.ExitOnError)
+	user := fs.String("user", "monitor", "Account user name")
+	host := fs.String("host", "localhost", "Account host")
+	fs.Parse(args)
+
+	for _, stmt := range db.GrantStatements(*user, *host) {
+		fmt.Println(stmt)
 	}
 }
 
-func checkDebian12() bool {
-	f, err := os.Open("/etc/os-release")
-	if err != nil {
-		return false
-	}
-	defer f.Close()
-
-	var isDebian, isVersion12 bool
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "ID=") {
-			val := strings.TrimPrefix(line, "ID=")
-			val = strings.Trim(val, `"`)
-			isDebian = val == "debian"
-		}
-		if strings.HasPrefix(line, "VERSION_ID=") {
-			val := strings.TrimPrefix(line, "VERSION_ID=")
-			val = strings.Trim(val, `"`)
-			isVersion12 = strings.HasPrefix(val, "12")
-		}
+func connectExitCode(kind db.ConnectErrorKind) int {
+	switch kind {
+	case db.ConnErrAuth:
+		return exitAuth
+	case db.ConnErrNetwork:
+		return exitNetwork
+	case db.ConnErrSocket:
+		return exitSocket
+	case db.ConnErrTooManyConnections:
+		return exitTooManyConn
+	case db.ConnErrTLS:
+		return exitTLS
+	case db.ConnErrTimeout:
+		return exitTimeout
+	case db.ConnErrHostBlocked:
+		return exitHostBlocked
+	default:
+		return exitFailed
 	}
-	return isDebian && isVersion12
 }